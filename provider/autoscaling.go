@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Scalable dimensions of an index, with the capacity attributes and autoscaling flags they match.
+var autoscalingDimensions = []struct {
+	dimension string
+	capacity  string
+	ratio     string
+	enabled   string
+	detected  string
+}{
+	{
+		dimension: applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits,
+		capacity:  "read_capacity",
+		ratio:     "read_capacity_ratio",
+		enabled:   "read_autoscaling_enabled",
+		detected:  "read_autoscaling_detected",
+	},
+	{
+		dimension: applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits,
+		capacity:  "write_capacity",
+		ratio:     "write_capacity_ratio",
+		enabled:   "write_autoscaling_enabled",
+		detected:  "write_autoscaling_detected",
+	},
+}
+
+// autoscalingResourceID returns the Application Auto Scaling resource ID of an index.
+func autoscalingResourceID(tn string, in string) string {
+	return fmt.Sprintf("table/%s/index/%s", tn, in)
+}
+
+// describeScalableTargets returns the Application Auto Scaling targets of an index by scalable
+// dimension. No targets are returned if the detection is disabled.
+func (c *dynamoDBClient) describeScalableTargets(ctx context.Context, tn string, in string) (map[string]*applicationautoscaling.ScalableTarget, error) {
+	targets := map[string]*applicationautoscaling.ScalableTarget{}
+	if c.autoscaling == nil {
+		return targets, nil
+	}
+
+	err := c.autoscaling.DescribeScalableTargetsPagesWithContext(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace: aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+		ResourceIds:      aws.StringSlice([]string{autoscalingResourceID(tn, in)}),
+	}, func(out *applicationautoscaling.DescribeScalableTargetsOutput, _ bool) bool {
+		for _, t := range out.ScalableTargets {
+			targets[aws.StringValue(t.ScalableDimension)] = t
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading Application Auto Scaling targets of DynamoDB GSI (%s) on table %s: %w", in, tn, err)
+	}
+
+	return targets, nil
+}
+
+// scalableTargets returns the Application Auto Scaling targets of an index if their detection is
+// enabled on the provider.
+func (p *GSIProvider) scalableTargets(ctx context.Context, c *dynamoDBClient, tn string, in string) (map[string]*applicationautoscaling.ScalableTarget, error) {
+	if !p.detectAutoscaling {
+		return map[string]*applicationautoscaling.ScalableTarget{}, nil
+	}
+	return c.describeScalableTargets(ctx, tn, in)
+}
+
+// deleteScalableTargets deletes the scaling policies of an index and deregisters its scalable targets,
+// the targets and policies already gone are ignored.
+func (c *dynamoDBClient) deleteScalableTargets(ctx context.Context, tn string, in string) error {
+	if c.autoscaling == nil {
+		return nil
+	}
+
+	id := autoscalingResourceID(tn, in)
+	var policies []*applicationautoscaling.ScalingPolicy
+	err := c.autoscaling.DescribeScalingPoliciesPagesWithContext(ctx, &applicationautoscaling.DescribeScalingPoliciesInput{
+		ServiceNamespace: aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+		ResourceId:       aws.String(id),
+	}, func(out *applicationautoscaling.DescribeScalingPoliciesOutput, _ bool) bool {
+		policies = append(policies, out.ScalingPolicies...)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("error reading Application Auto Scaling policies of DynamoDB GSI (%s) on table %s: %w", in, tn, err)
+	}
+
+	for _, policy := range policies {
+		_, err := c.autoscaling.DeleteScalingPolicyWithContext(ctx, &applicationautoscaling.DeleteScalingPolicyInput{
+			ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+			ResourceId:        aws.String(id),
+			ScalableDimension: policy.ScalableDimension,
+			PolicyName:        policy.PolicyName,
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == applicationautoscaling.ErrCodeObjectNotFoundException {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting Application Auto Scaling policy %s of DynamoDB GSI (%s) on table %s: %w", aws.StringValue(policy.PolicyName), in, tn, err)
+		}
+	}
+
+	targets, err := c.describeScalableTargets(ctx, tn, in)
+	if err != nil {
+		return err
+	}
+
+	for dimension := range targets {
+		_, err := c.autoscaling.DeregisterScalableTargetWithContext(ctx, &applicationautoscaling.DeregisterScalableTargetInput{
+			ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+			ResourceId:        aws.String(id),
+			ScalableDimension: aws.String(dimension),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == applicationautoscaling.ErrCodeObjectNotFoundException {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error deregistering Application Auto Scaling target %s of DynamoDB GSI (%s) on table %s: %w", dimension, in, tn, err)
+		}
+	}
+
+	return nil
+}
+
+func flattenScalableTargets(d *schema.ResourceData, targets map[string]*applicationautoscaling.ScalableTarget) {
+	for _, dim := range autoscalingDimensions {
+		d.Set(dim.detected, targets[dim.dimension] != nil)
+	}
+	d.Set("autoscaling_detected", len(targets) > 0)
+}
+
+// autoscaled returns whether the capacity of a dimension is controlled by an autoscaler, either
+// because it is configured so or because a scalable target exists.
+func autoscaled(d *schema.ResourceData, targets map[string]*applicationautoscaling.ScalableTarget, dimension string) bool {
+	for _, dim := range autoscalingDimensions {
+		if dim.dimension == dimension {
+			return d.Get(dim.enabled).(bool) || targets[dimension] != nil
+		}
+	}
+	return false
+}
+
+// rawConfigGetter reads the configuration of a resource from either its data or its diff.
+type rawConfigGetter interface {
+	GetRawConfig() cty.Value
+}
+
+// configuredCapacity returns the capacity of a dimension as configured, the state holds the live
+// capacity of the autoscaled dimensions instead.
+func configuredCapacity(d rawConfigGetter, capacity string) (int64, bool) {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return 0, false
+	}
+
+	v := config.GetAttr(capacity)
+	if v.IsNull() || !v.IsKnown() {
+		return 0, false
+	}
+	c, _ := v.AsBigFloat().Int64()
+	return c, true
+}
+
+// outOfBounds returns whether a capacity is outside the bounds of a scalable target.
+func outOfBounds(capacity int64, t *applicationautoscaling.ScalableTarget) bool {
+	return capacity < aws.Int64Value(t.MinCapacity) || capacity > aws.Int64Value(t.MaxCapacity)
+}
+
+// scalableTargetWarnings warns about the configured capacities which are outside the bounds of the
+// scalable target of their dimension, the autoscaler would move them back within the bounds.
+func scalableTargetWarnings(d rawConfigGetter, targets map[string]*applicationautoscaling.ScalableTarget, tn string, in string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, dim := range autoscalingDimensions {
+		t := targets[dim.dimension]
+		capacity, ok := configuredCapacity(d, dim.capacity)
+		if t == nil || !ok || !outOfBounds(capacity, t) {
+			continue
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("%s of DynamoDB GSI (%s) on table %s is outside the autoscaling bounds", dim.capacity, in, tn),
+			Detail:   fmt.Sprintf("The configured capacity is %d but the scalable target %s only allows %d to %d.", capacity, dim.dimension, aws.Int64Value(t.MinCapacity), aws.Int64Value(t.MaxCapacity)),
+		})
+	}
+
+	return diags
+}
@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// fakeAutoscaling serves scalable targets and scaling policies by resource ID.
+type fakeAutoscaling struct {
+	applicationautoscalingiface.ApplicationAutoScalingAPI
+	targets  map[string][]*applicationautoscaling.ScalableTarget
+	policies map[string][]*applicationautoscaling.ScalingPolicy
+}
+
+func (f *fakeAutoscaling) DescribeScalingPoliciesPagesWithContext(_ aws.Context, input *applicationautoscaling.DescribeScalingPoliciesInput, fn func(*applicationautoscaling.DescribeScalingPoliciesOutput, bool) bool, _ ...request.Option) error {
+	fn(&applicationautoscaling.DescribeScalingPoliciesOutput{
+		ScalingPolicies: f.policies[aws.StringValue(input.ResourceId)],
+	}, true)
+	return nil
+}
+
+func (f *fakeAutoscaling) DeleteScalingPolicyWithContext(_ aws.Context, input *applicationautoscaling.DeleteScalingPolicyInput, _ ...request.Option) (*applicationautoscaling.DeleteScalingPolicyOutput, error) {
+	id := aws.StringValue(input.ResourceId)
+	for idx, p := range f.policies[id] {
+		if aws.StringValue(p.PolicyName) == aws.StringValue(input.PolicyName) {
+			f.policies[id] = append(f.policies[id][:idx], f.policies[id][idx+1:]...)
+			return &applicationautoscaling.DeleteScalingPolicyOutput{}, nil
+		}
+	}
+	return nil, awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "policy not found", nil)
+}
+
+func (f *fakeAutoscaling) DeregisterScalableTargetWithContext(_ aws.Context, input *applicationautoscaling.DeregisterScalableTargetInput, _ ...request.Option) (*applicationautoscaling.DeregisterScalableTargetOutput, error) {
+	id := aws.StringValue(input.ResourceId)
+	for idx, t := range f.targets[id] {
+		if aws.StringValue(t.ScalableDimension) == aws.StringValue(input.ScalableDimension) {
+			f.targets[id] = append(f.targets[id][:idx], f.targets[id][idx+1:]...)
+			return &applicationautoscaling.DeregisterScalableTargetOutput{}, nil
+		}
+	}
+	return nil, awserr.New(applicationautoscaling.ErrCodeObjectNotFoundException, "target not found", nil)
+}
+
+func (f *fakeAutoscaling) DescribeScalableTargetsPagesWithContext(_ aws.Context, input *applicationautoscaling.DescribeScalableTargetsInput, fn func(*applicationautoscaling.DescribeScalableTargetsOutput, bool) bool, _ ...request.Option) error {
+	out := &applicationautoscaling.DescribeScalableTargetsOutput{}
+	for _, id := range input.ResourceIds {
+		out.ScalableTargets = append(out.ScalableTargets, f.targets[aws.StringValue(id)]...)
+	}
+	fn(out, true)
+	return nil
+}
+
+func TestDescribeScalableTargets(t *testing.T) {
+	c := newDynamoDBClient(nil, "", nil, nil, nil)
+	targets, err := c.describeScalableTargets(context.Background(), "test_table", "basic_index")
+	if err != nil || len(targets) != 0 {
+		t.Fatalf("expected no targets with the detection disabled, got %v, %v", targets, err)
+	}
+
+	c.autoscaling = &fakeAutoscaling{
+		targets: map[string][]*applicationautoscaling.ScalableTarget{
+			"table/test_table/index/basic_index": {
+				{
+					ResourceId:        aws.String("table/test_table/index/basic_index"),
+					ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits),
+					MinCapacity:       aws.Int64(5),
+					MaxCapacity:       aws.Int64(50),
+				},
+			},
+		},
+	}
+	targets, err = c.describeScalableTargets(context.Background(), "test_table", "basic_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, map[string]interface{}{
+		"read_capacity":  100,
+		"write_capacity": 100,
+	})
+	flattenScalableTargets(d, targets)
+	if !d.Get("autoscaling_detected").(bool) || !d.Get("read_autoscaling_detected").(bool) || d.Get("write_autoscaling_detected").(bool) {
+		t.Errorf("expected only read autoscaling to be detected, got %v", targets)
+	}
+
+	// The state holds the live capacity, only the configured one is checked.
+	if diags := scalableTargetWarnings(d, targets, "test_table", "basic_index"); len(diags) != 0 {
+		t.Errorf("expected no warnings without configuration, got %v", diags)
+	}
+
+	diags := scalableTargetWarnings(testRawConfig(100, 100), targets, "test_table", "basic_index")
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("expected a warning for the read capacity, got %v", diags)
+	}
+
+	if diags := scalableTargetWarnings(testRawConfig(50, 100), targets, "test_table", "basic_index"); len(diags) != 0 {
+		t.Errorf("expected no warnings within the bounds, got %v", diags)
+	}
+}
+
+// rawConfig is a configuration with only capacities.
+type rawConfig cty.Value
+
+func (c rawConfig) GetRawConfig() cty.Value {
+	return cty.Value(c)
+}
+
+func testRawConfig(readCapacity, writeCapacity int64) rawConfig {
+	return rawConfig(cty.ObjectVal(map[string]cty.Value{
+		"read_capacity":  cty.NumberIntVal(readCapacity),
+		"write_capacity": cty.NumberIntVal(writeCapacity),
+	}))
+}
+
+func TestDeleteScalableTargets(t *testing.T) {
+	id := "table/test_table/index/basic_index"
+	f := &fakeAutoscaling{
+		targets: map[string][]*applicationautoscaling.ScalableTarget{
+			id: {
+				{ResourceId: aws.String(id), ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits)},
+				{ResourceId: aws.String(id), ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits)},
+			},
+		},
+		policies: map[string][]*applicationautoscaling.ScalingPolicy{
+			id: {
+				{PolicyName: aws.String("read"), ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits)},
+				{PolicyName: aws.String("write"), ScalableDimension: aws.String(applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits)},
+			},
+		},
+	}
+	c := newDynamoDBClient(nil, "", nil, nil, nil)
+	c.autoscaling = f
+
+	if err := c.deleteScalableTargets(context.Background(), "test_table", "basic_index"); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.targets[id]) != 0 || len(f.policies[id]) != 0 {
+		t.Errorf("expected the targets and policies to be deleted, got %v and %v", f.targets[id], f.policies[id])
+	}
+
+	// Nothing is left to delete the second time.
+	if err := c.deleteScalableTargets(context.Background(), "test_table", "basic_index"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	// Write request units per second assumed for the backfill of an index on an on-demand table, which
+	// initially serves up to 4,000 write request units.
+	onDemandBackfillWriteUnits = 4000
+
+	writeUnitSize = 1024
+)
+
+// estimateBackfill estimates how long the backfill of an index takes, each item of the table being
+// written to the index at the given write capacity. The item count and size of a table are only
+// refreshed every 6 hours by DynamoDB.
+func estimateBackfill(t *dynamodb.TableDescription, billingMode string, writeCapacity int64) time.Duration {
+	items := aws.Int64Value(t.ItemCount)
+	if items == 0 {
+		return 0
+	}
+
+	unitsPerItem := int64(math.Ceil(float64(aws.Int64Value(t.TableSizeBytes)) / float64(items) / writeUnitSize))
+	if unitsPerItem < 1 {
+		unitsPerItem = 1
+	}
+
+	rate := writeCapacity
+	if billingMode == dynamodb.BillingModePayPerRequest {
+		rate = onDemandBackfillWriteUnits
+	}
+	if rate < 1 {
+		return 0
+	}
+
+	seconds := math.Ceil(float64(items*unitsPerItem) / float64(rate))
+	return time.Duration(seconds) * time.Second
+}
+
+// customizeDiffBackfillEstimate shows in the plan how long the backfill of an index being created or
+// replaced is expected to take. The plan cannot carry warnings, the estimate is an attribute.
+func customizeDiffBackfillEstimate(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	// Replaced indexes are diffed again without state, they also have no ID here.
+	p, ok := m.(*GSIProvider)
+	if !ok || d.Id() != "" {
+		return nil
+	}
+
+	writeCapacity := "write_capacity"
+	if _, ok := d.GetOk("backfill_write_capacity"); ok {
+		writeCapacity = "backfill_write_capacity"
+	}
+	if !d.NewValueKnown("table_name") || !d.NewValueKnown(writeCapacity) {
+		return d.SetNewComputed("estimated_backfill_duration")
+	}
+
+	region, tn, err := resolveTable(d)
+	if err != nil {
+		return err
+	}
+	t, err := p.client(region).describeTable(ctx, tn)
+	if err != nil {
+		// The table may be created in the same apply, it is then empty.
+		log.Printf("[DEBUG] Could not estimate the backfill of DynamoDB GSI on table %s: %s", tn, err)
+		return d.SetNewComputed("estimated_backfill_duration")
+	}
+
+	estimate := estimateBackfill(t, d.Get("billing_mode").(string), int64(d.Get(writeCapacity).(int)))
+	return d.SetNew("estimated_backfill_duration", estimate.String())
+}
+
+// backfillEstimateWarning warns about the backfills estimated to take a minute or more when the index
+// is created, the plan only shows the estimate as an attribute.
+func backfillEstimateWarning(d *schema.ResourceData, tn string, in string) diag.Diagnostics {
+	estimate, err := time.ParseDuration(d.Get("estimated_backfill_duration").(string))
+	if err != nil || estimate < time.Minute {
+		return nil
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("backfill of DynamoDB GSI (%s) on table %s is estimated to take %s", in, tn, estimate),
+		Detail:   "The estimate is based on the item count and size of the table, which DynamoDB refreshes every 6 hours, and the write capacity of the index.",
+	}}
+}
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestEstimateBackfill(t *testing.T) {
+	cases := []struct {
+		name          string
+		items         int64
+		size          int64
+		billingMode   string
+		writeCapacity int64
+		estimate      time.Duration
+	}{
+		{"empty table", 0, 0, dynamodb.BillingModeProvisioned, 5, 0},
+		{"small items", 36000, 36000 * 100, dynamodb.BillingModeProvisioned, 10, time.Hour},
+		{"large items", 3600, 3600 * 3000, dynamodb.BillingModeProvisioned, 3, time.Hour},
+		{"on-demand", 4000 * 3600, 4000 * 3600 * 100, dynamodb.BillingModePayPerRequest, 0, time.Hour},
+	}
+	for _, tc := range cases {
+		table := &dynamodb.TableDescription{
+			ItemCount:      aws.Int64(tc.items),
+			TableSizeBytes: aws.Int64(tc.size),
+		}
+		if estimate := estimateBackfill(table, tc.billingMode, tc.writeCapacity); estimate != tc.estimate {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.estimate, estimate)
+		}
+	}
+}
+
+func TestBackfillEstimateWarning(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, map[string]interface{}{})
+	for estimate, warned := range map[string]bool{"": false, "30s": false, "1h0m0s": true} {
+		d.Set("estimated_backfill_duration", estimate)
+		if diags := backfillEstimateWarning(d, "test_table", "basic_index"); (len(diags) == 1) != warned {
+			t.Errorf("%q: expected a warning %t, got %v", estimate, warned, diags)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Terraform updates the indexes of a table concurrently, the updates received within this window are
+// sent together.
+const updateBatchWindow = time.Second
+
+// tableUpdate is the part of an UpdateTable call contributed by an index.
+type tableUpdate struct {
+	index *dynamodb.UpdateGlobalSecondaryIndexAction
+	// billingMode switches the billing mode of the table, with the throughput of the table when it
+	// switches to PROVISIONED.
+	billingMode string
+	throughput  *dynamodb.ProvisionedThroughput
+}
+
+type updateBatch struct {
+	updates []*tableUpdate
+	// done is closed once the batch is sent.
+	done chan struct{}
+	err  error
+}
+
+// updateBatcher groups the updates of the indexes of a table into a single UpdateTable call.
+type updateBatcher struct {
+	mu      sync.Mutex
+	batches map[string]*updateBatch
+}
+
+func newUpdateBatcher() *updateBatcher {
+	return &updateBatcher{
+		batches: map[string]*updateBatch{},
+	}
+}
+
+// updateGSI sends an index update, batched with the updates of the other indexes of the table if
+// the client has a batcher.
+func (c *dynamoDBClient) updateGSI(ctx context.Context, tn string, update *tableUpdate) error {
+	if c.batcher == nil {
+		input, err := updateTableInput(tn, []*tableUpdate{update})
+		if err != nil {
+			return err
+		}
+		_, err = c.updateTable(ctx, input)
+		return err
+	}
+
+	return c.batcher.add(ctx, c.region+":"+tn, update, func(updates []*tableUpdate) error {
+		input, err := updateTableInput(tn, updates)
+		if err != nil {
+			return err
+		}
+
+		// The batch is shared by several resources, it is not bound to the context of any of them.
+		_, err = c.updateTable(context.Background(), input)
+		return err
+	})
+}
+
+// updateTableInput merges the updates of the indexes of a table, which must agree on the billing mode
+// and throughput of the table.
+func updateTableInput(tn string, updates []*tableUpdate) (*dynamodb.UpdateTableInput, error) {
+	input := &dynamodb.UpdateTableInput{
+		TableName: aws.String(tn),
+	}
+	for _, u := range updates {
+		if u.index != nil {
+			input.GlobalSecondaryIndexUpdates = append(input.GlobalSecondaryIndexUpdates, &dynamodb.GlobalSecondaryIndexUpdate{
+				Update: u.index,
+			})
+		}
+
+		if u.billingMode == "" {
+			continue
+		}
+		if input.BillingMode != nil && aws.StringValue(input.BillingMode) != u.billingMode {
+			return nil, fmt.Errorf("conflicting billing modes requested for table %s: %s and %s", tn, aws.StringValue(input.BillingMode), u.billingMode)
+		}
+		input.BillingMode = aws.String(u.billingMode)
+
+		if u.throughput == nil {
+			continue
+		}
+		if input.ProvisionedThroughput != nil && (aws.Int64Value(input.ProvisionedThroughput.ReadCapacityUnits) != aws.Int64Value(u.throughput.ReadCapacityUnits) ||
+			aws.Int64Value(input.ProvisionedThroughput.WriteCapacityUnits) != aws.Int64Value(u.throughput.WriteCapacityUnits)) {
+			return nil, fmt.Errorf("conflicting table capacities requested for table %s", tn)
+		}
+		input.ProvisionedThroughput = u.throughput
+	}
+
+	return input, nil
+}
+
+// add queues an update in the batch of the table and waits for the batch to be sent. The first update
+// of a batch schedules send to be called with all the updates at the end of the batch window.
+func (b *updateBatcher) add(ctx context.Context, key string, update *tableUpdate, send func([]*tableUpdate) error) error {
+	b.mu.Lock()
+	batch, ok := b.batches[key]
+	if !ok {
+		batch = &updateBatch{done: make(chan struct{})}
+		b.batches[key] = batch
+		time.AfterFunc(updateBatchWindow, func() {
+			b.flush(key, batch, send)
+		})
+	}
+	batch.updates = append(batch.updates, update)
+	b.mu.Unlock()
+
+	select {
+	case <-batch.done:
+		return batch.err
+	case <-ctx.Done():
+		// Withdraw the update if the batch is not sent yet.
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.batches[key] == batch {
+			for idx, u := range batch.updates {
+				if u == update {
+					batch.updates = append(batch.updates[:idx], batch.updates[idx+1:]...)
+					break
+				}
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+func (b *updateBatcher) flush(key string, batch *updateBatch, send func([]*tableUpdate) error) {
+	b.mu.Lock()
+	delete(b.batches, key)
+	updates := batch.updates
+	b.mu.Unlock()
+
+	if len(updates) > 0 {
+		batch.err = send(updates)
+	}
+	close(batch.done)
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestUpdateBatcher(t *testing.T) {
+	b := newUpdateBatcher()
+
+	var mu sync.Mutex
+	sent := [][]*tableUpdate{}
+	send := func(updates []*tableUpdate) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, updates)
+		return errors.New("throttled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, in := range []string{"index_1", "index_2", "index_3"} {
+		wg.Add(1)
+		go func(in string) {
+			defer wg.Done()
+			err := b.add(context.Background(), "test_table", &tableUpdate{index: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: aws.String(in)}}, send)
+			if err == nil || err.Error() != "throttled" {
+				t.Errorf("%s: expected the batch error, got %v", in, err)
+			}
+		}(in)
+	}
+
+	// A cancelled update is withdrawn from the batch.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := b.add(ctx, "test_table", &tableUpdate{index: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: aws.String("index_4")}}, send); err != context.Canceled {
+			t.Errorf("expected the update to be cancelled, got %v", err)
+		}
+	}()
+	cancel()
+	wg.Wait()
+
+	if len(sent) != 1 {
+		t.Fatalf("expected a single batch, got %d", len(sent))
+	}
+	if len(sent[0]) != 3 {
+		t.Errorf("expected 3 updates in the batch, got %d", len(sent[0]))
+	}
+	for _, u := range sent[0] {
+		if aws.StringValue(u.index.IndexName) == "index_4" {
+			t.Error("expected the cancelled update to be withdrawn")
+		}
+	}
+}
+
+func TestUpdateTableInput(t *testing.T) {
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(10),
+		WriteCapacityUnits: aws.Int64(10),
+	}
+	updates := []*tableUpdate{
+		{index: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: aws.String("index_1")}, billingMode: dynamodb.BillingModeProvisioned, throughput: throughput},
+		{index: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: aws.String("index_2")}, billingMode: dynamodb.BillingModeProvisioned, throughput: throughput},
+		{index: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: aws.String("index_3")}},
+	}
+
+	input, err := updateTableInput("test_table", updates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(input.GlobalSecondaryIndexUpdates) != 3 || aws.StringValue(input.BillingMode) != dynamodb.BillingModeProvisioned || input.ProvisionedThroughput != throughput {
+		t.Errorf("expected the updates to be merged with the table switch, got %v", input)
+	}
+
+	updates = append(updates, &tableUpdate{billingMode: dynamodb.BillingModePayPerRequest})
+	if _, err := updateTableInput("test_table", updates); err == nil {
+		t.Error("expected an error for conflicting billing modes")
+	}
+}
@@ -0,0 +1,317 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// customizeDiffCapacityFromTable resolves the capacities configured as a ratio of the capacity of the
+// table so that the plan shows the capacities applied to the index.
+func customizeDiffCapacityFromTable(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	p, ok := m.(*GSIProvider)
+	if !ok || d.Get("billing_mode").(string) != dynamodb.BillingModeProvisioned {
+		return nil
+	}
+
+	var throughput *dynamodb.ProvisionedThroughput
+	resolved := false
+	for _, dim := range autoscalingDimensions {
+		ratio, ok := d.GetOk(dim.ratio)
+		if !ok {
+			continue
+		}
+		// The capacity is left to the autoscaler once the index exists.
+		if d.Id() != "" && (d.Get(dim.enabled).(bool) || d.Get(dim.detected).(bool)) {
+			continue
+		}
+
+		if !resolved && d.NewValueKnown("table_name") {
+			region, tn, err := resolveTable(d)
+			if err != nil {
+				return err
+			}
+			if throughput, err = tableCapacity(ctx, d, p.client(region), tn); err != nil {
+				return err
+			}
+			resolved = true
+		}
+
+		// The table may not exist yet, the capacity is then resolved when applying.
+		if throughput == nil {
+			if err := d.SetNewComputed(dim.capacity); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.SetNew(dim.capacity, capacityFromRatio(ratio.(float64), dimensionCapacity(throughput, dim.dimension))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// customizeDiffPayPerRequestCapacity plans no capacity for a PAY_PER_REQUEST index whose capacities are
+// not configured, the capacities being computed they would otherwise keep the values of an index
+// switching from PROVISIONED.
+func customizeDiffPayPerRequestCapacity(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Get("billing_mode").(string) != dynamodb.BillingModePayPerRequest {
+		return nil
+	}
+
+	// The configured capacities are rejected when applying. The configuration is not available when
+	// the diff of a replaced index is computed again, its capacities are then configured or unknown.
+	config := d.GetRawConfig()
+	for _, dim := range autoscalingDimensions {
+		if !config.IsNull() && !config.GetAttr(dim.capacity).IsNull() {
+			continue
+		}
+		if d.NewValueKnown(dim.capacity) && (config.IsNull() || d.Get(dim.capacity).(int) == 0) {
+			continue
+		}
+		if err := d.SetNew(dim.capacity, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveCapacityRatios sets the capacities configured as a ratio which were left unknown in the plan
+// because the table did not exist yet.
+func resolveCapacityRatios(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string) error {
+	if d.Get("billing_mode").(string) != dynamodb.BillingModeProvisioned {
+		return nil
+	}
+
+	var throughput *dynamodb.ProvisionedThroughput
+	for _, dim := range autoscalingDimensions {
+		ratio, ok := d.GetOk(dim.ratio)
+		if !ok || d.Get(dim.capacity).(int) != 0 {
+			continue
+		}
+
+		if throughput == nil {
+			var err error
+			if throughput, err = tableCapacity(ctx, d, c, tn); err != nil {
+				return err
+			}
+			if throughput == nil {
+				return fmt.Errorf("dynamodb table (%s) not found", tn)
+			}
+		}
+
+		if err := d.Set(dim.capacity, capacityFromRatio(ratio.(float64), dimensionCapacity(throughput, dim.dimension))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tableCapacity returns the provisioned capacity of the table, or the one it is switched to along with
+// the index. Nil is returned if the table does not exist.
+func tableCapacity(ctx context.Context, d resourceGetter, c *dynamoDBClient, tn string) (*dynamodb.ProvisionedThroughput, error) {
+	if d.Get("switch_table_billing_mode").(bool) {
+		if read, write := d.Get("table_read_capacity").(int), d.Get("table_write_capacity").(int); read != 0 && write != 0 {
+			return &dynamodb.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(int64(read)),
+				WriteCapacityUnits: aws.Int64(int64(write)),
+			}, nil
+		}
+	}
+
+	t, err := c.describeTable(ctx, tn)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading Dynamodb Table (%s): %w", tn, err)
+	}
+	if tableBillingMode(t) != dynamodb.BillingModeProvisioned || t.ProvisionedThroughput == nil {
+		return nil, fmt.Errorf("capacity ratios require table %s to be PROVISIONED", tn)
+	}
+
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  t.ProvisionedThroughput.ReadCapacityUnits,
+		WriteCapacityUnits: t.ProvisionedThroughput.WriteCapacityUnits,
+	}, nil
+}
+
+// dimensionCapacity returns the capacity of a throughput for a scalable dimension.
+func dimensionCapacity(throughput *dynamodb.ProvisionedThroughput, dimension string) int64 {
+	if dimension == applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits {
+		return aws.Int64Value(throughput.WriteCapacityUnits)
+	}
+	return aws.Int64Value(throughput.ReadCapacityUnits)
+}
+
+// capacityFromRatio returns the ratio of a table capacity rounded up, and at least 1.
+func capacityFromRatio(ratio float64, tableCapacity int64) int {
+	// Tolerate the rounding errors of the product, 0.1 * 50 must give 5 rather than 6.
+	capacity := int(math.Ceil(ratio*float64(tableCapacity) - 1e-9))
+	if capacity < 1 {
+		return 1
+	}
+	return capacity
+}
+
+// DynamoDB allows a few capacity decreases at any time of a UTC day, then one per hour without decrease.
+const (
+	freeDecreasesPerDay = 4
+	maxDecreasesPerDay  = 27
+	decreaseInterval    = time.Hour
+)
+
+// Policies for the capacity decreases DynamoDB would reject.
+const (
+	capacityDecreaseQuotaFail   = "fail"
+	capacityDecreaseQuotaDefer  = "defer"
+	capacityDecreaseQuotaIgnore = "ignore"
+)
+
+// nextDecrease returns when the next capacity decrease of an index is allowed, now if it already is.
+func nextDecrease(live *dynamodb.ProvisionedThroughputDescription, now time.Time) time.Time {
+	now = now.UTC()
+	today := now.Truncate(24 * time.Hour)
+	tomorrow := today.Add(24 * time.Hour)
+
+	if live == nil || live.LastDecreaseDateTime == nil {
+		return now
+	}
+	last := live.LastDecreaseDateTime.UTC()
+
+	// The counter of the last decrease day is reported until the next decrease.
+	decreases := aws.Int64Value(live.NumberOfDecreasesToday)
+	if last.Before(today) {
+		decreases = 0
+	}
+
+	switch {
+	case decreases < freeDecreasesPerDay:
+		return now
+	case decreases >= maxDecreasesPerDay:
+		return tomorrow
+	}
+
+	next := last.Add(decreaseInterval)
+	if next.Before(now) {
+		return now
+	}
+	if next.After(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
+
+// decreasedDimensions returns the capacities of a throughput update lower than the live ones.
+func decreasedDimensions(throughput *dynamodb.ProvisionedThroughput, live *dynamodb.ProvisionedThroughputDescription) []string {
+	if throughput == nil || live == nil {
+		return nil
+	}
+
+	var decreased []string
+	if aws.Int64Value(throughput.ReadCapacityUnits) < aws.Int64Value(live.ReadCapacityUnits) {
+		decreased = append(decreased, "read_capacity")
+	}
+	if aws.Int64Value(throughput.WriteCapacityUnits) < aws.Int64Value(live.WriteCapacityUnits) {
+		decreased = append(decreased, "write_capacity")
+	}
+	return decreased
+}
+
+// deferDecreases keeps the live capacity of the decreased dimensions of a throughput update, nil is
+// returned if nothing is left to update.
+func deferDecreases(throughput *dynamodb.ProvisionedThroughput, live *dynamodb.ProvisionedThroughputDescription) *dynamodb.ProvisionedThroughput {
+	deferred := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  throughput.ReadCapacityUnits,
+		WriteCapacityUnits: throughput.WriteCapacityUnits,
+	}
+	if aws.Int64Value(deferred.ReadCapacityUnits) < aws.Int64Value(live.ReadCapacityUnits) {
+		deferred.ReadCapacityUnits = live.ReadCapacityUnits
+	}
+	if aws.Int64Value(deferred.WriteCapacityUnits) < aws.Int64Value(live.WriteCapacityUnits) {
+		deferred.WriteCapacityUnits = live.WriteCapacityUnits
+	}
+
+	if aws.Int64Value(deferred.ReadCapacityUnits) == aws.Int64Value(live.ReadCapacityUnits) && aws.Int64Value(deferred.WriteCapacityUnits) == aws.Int64Value(live.WriteCapacityUnits) {
+		return nil
+	}
+	return deferred
+}
+
+func decreaseQuotaError(decreased []string, in string, tn string, next time.Time) error {
+	return fmt.Errorf("cannot decrease %s of DynamoDB GSI (%s) on table %s: the daily quota of capacity decreases is reached, the next decrease is allowed at %s", strings.Join(decreased, " and "), in, tn, next.Format(time.RFC3339))
+}
+
+// customizeDiffCapacityDecrease fails the plan when it decreases the capacity of an index more often
+// than DynamoDB allows, unless capacity_decrease_quota says otherwise.
+func customizeDiffCapacityDecrease(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	p, ok := m.(*GSIProvider)
+	if !ok || d.Id() == "" || d.Get("capacity_decrease_quota").(string) != capacityDecreaseQuotaFail {
+		return nil
+	}
+	if d.Get("billing_mode").(string) != dynamodb.BillingModeProvisioned || d.HasChange("billing_mode") {
+		return nil
+	}
+	// A replacing index starts with its own quota.
+	if forceNewChange(d) {
+		return nil
+	}
+	if !d.HasChange("read_capacity") && !d.HasChange("write_capacity") {
+		return nil
+	}
+	if !d.NewValueKnown("read_capacity") || !d.NewValueKnown("write_capacity") {
+		return nil
+	}
+
+	region, tn, in, err := idToNames(d.Id())
+	if err != nil {
+		return err
+	}
+	_, i, err := describeGSI(ctx, p.client(region), tn, in)
+	if err != nil || i == nil || i.ProvisionedThroughput == nil {
+		return err
+	}
+
+	planned := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(int64(d.Get("read_capacity").(int))),
+		WriteCapacityUnits: aws.Int64(int64(d.Get("write_capacity").(int))),
+	}
+	// The autoscaled dimensions are not updated.
+	if d.Get("read_autoscaling_enabled").(bool) || d.Get("read_autoscaling_detected").(bool) {
+		planned.ReadCapacityUnits = i.ProvisionedThroughput.ReadCapacityUnits
+	}
+	if d.Get("write_autoscaling_enabled").(bool) || d.Get("write_autoscaling_detected").(bool) {
+		planned.WriteCapacityUnits = i.ProvisionedThroughput.WriteCapacityUnits
+	}
+
+	now := time.Now()
+	if decreased := decreasedDimensions(planned, i.ProvisionedThroughput); len(decreased) > 0 {
+		if next := nextDecrease(i.ProvisionedThroughput, now); next.After(now) {
+			return decreaseQuotaError(decreased, in, tn, next)
+		}
+	}
+
+	return nil
+}
+
+// forceNewChange returns whether a diff replaces the index.
+func forceNewChange(d interface{ HasChange(string) bool }) bool {
+	for k, s := range dynamoDBGSISchema() {
+		if s.ForceNew && d.HasChange(k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCapacityFromRatio(t *testing.T) {
+	cases := []struct {
+		ratio         float64
+		tableCapacity int64
+		capacity      int
+	}{
+		{1, 50, 50},
+		{0.1, 50, 5},
+		{0.5, 15, 8},
+		{0.01, 10, 1},
+		{0, 10, 1},
+		{2, 10, 20},
+	}
+	for _, tc := range cases {
+		if capacity := capacityFromRatio(tc.ratio, tc.tableCapacity); capacity != tc.capacity {
+			t.Errorf("%g of %d: expected %d, got %d", tc.ratio, tc.tableCapacity, tc.capacity, capacity)
+		}
+	}
+}
+
+func TestNextDecrease(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	tomorrow := time.Date(2023, 5, 11, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		decreases int64
+		last      time.Time
+		next      time.Time
+	}{
+		{"free decreases left", 3, now.Add(-time.Minute), now},
+		{"no decrease in the last hour", 4, now.Add(-2 * time.Hour), now},
+		{"decrease in the last hour", 4, now.Add(-20 * time.Minute), now.Add(40 * time.Minute)},
+		{"counter of a previous day", 10, now.Add(-24 * time.Hour), now},
+		{"daily maximum", 27, now.Add(-2 * time.Hour), tomorrow},
+	}
+	for _, tc := range cases {
+		live := &dynamodb.ProvisionedThroughputDescription{
+			NumberOfDecreasesToday: aws.Int64(tc.decreases),
+			LastDecreaseDateTime:   aws.Time(tc.last),
+		}
+		if next := nextDecrease(live, now); !next.Equal(tc.next) {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.next, next)
+		}
+	}
+
+	if next := nextDecrease(&dynamodb.ProvisionedThroughputDescription{}, now); !next.Equal(now) {
+		t.Errorf("expected a decrease to be allowed without previous decrease, got %s", next)
+	}
+}
+
+func TestDeferDecreases(t *testing.T) {
+	live := &dynamodb.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(10),
+		WriteCapacityUnits: aws.Int64(10),
+	}
+
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(5),
+		WriteCapacityUnits: aws.Int64(20),
+	}
+	if decreased := decreasedDimensions(throughput, live); len(decreased) != 1 || decreased[0] != "read_capacity" {
+		t.Errorf("expected read_capacity to be decreased, got %v", decreased)
+	}
+	deferred := deferDecreases(throughput, live)
+	if deferred == nil || aws.Int64Value(deferred.ReadCapacityUnits) != 10 || aws.Int64Value(deferred.WriteCapacityUnits) != 20 {
+		t.Errorf("expected the increase to be kept alone, got %v", deferred)
+	}
+
+	throughput.WriteCapacityUnits = aws.Int64(5)
+	if deferred := deferDecreases(throughput, live); deferred != nil {
+		t.Errorf("expected nothing left to update, got %v", deferred)
+	}
+}
+
+// changes is a diff of the given attributes.
+type changes []string
+
+func (c changes) HasChange(key string) bool {
+	for _, k := range c {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestForceNewChange(t *testing.T) {
+	if forceNewChange(changes{"read_capacity", "write_capacity"}) {
+		t.Error("expected a capacity change to update the index")
+	}
+	if !forceNewChange(changes{"hash_key", "write_capacity"}) {
+		t.Error("expected a hash_key change to replace the index")
+	}
+}
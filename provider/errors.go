@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	errCodeAccessDenied = "AccessDeniedException"
+	errCodeValidation   = "ValidationException"
+
+	kmsHint = "The KMS key used to encrypt the table is disabled or inaccessible. Restore access to the key and wait for the table to become ACTIVE again."
+)
+
+// Codes returned when the KMS key protecting a table cannot be used.
+var kmsErrorCodes = []string{
+	"KMSAccessDeniedException",
+	"KMSDisabledException",
+	"KMSInvalidStateException",
+	"KMSNotFoundException",
+}
+
+// errorHint returns a remediation hint for the AWS errors commonly returned when managing an index.
+func errorHint(aerr awserr.Error) string {
+	code := aerr.Code()
+	for _, c := range kmsErrorCodes {
+		if code == c {
+			return kmsHint
+		}
+	}
+
+	switch code {
+	case errCodeValidation:
+		if strings.Contains(aerr.Message(), "KMS") {
+			return kmsHint
+		}
+		return "Check that the key attribute types and the projection are compatible with the table definition, and that the capacity settings match the billing mode of the table."
+	case dynamodb.ErrCodeLimitExceededException:
+		return "DynamoDB limits the number of indexes created at once on a table, the number of concurrent table updates and the number of capacity decreases per day. Retry later or apply with a lower -parallelism, capacity_decrease_quota = \"defer\" keeps the current capacity until a decrease is allowed."
+	case dynamodb.ErrCodeResourceInUseException:
+		return "The table or one of its indexes is being updated. Wait for the pending change to complete and apply again."
+	case errCodeAccessDenied:
+		return "Grant the caller dynamodb:DescribeTable and dynamodb:UpdateTable on the table and its indexes, and application-autoscaling:DescribeScalableTargets if detect_autoscaling = true."
+	}
+
+	return ""
+}
+
+// errorDiags converts an error into diagnostics. If the error comes from AWS, the diagnostic keeps the
+// error code and request ID and includes a remediation hint when one is known.
+func errorDiags(err error) diag.Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return diag.FromErr(err)
+	}
+
+	detail := fmt.Sprintf("AWS error code: %s", aerr.Code())
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) && rerr.RequestID() != "" {
+		detail += fmt.Sprintf(", request ID: %s", rerr.RequestID())
+	}
+	if hint := errorHint(aerr); hint != "" {
+		detail += "\n\n" + hint
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  err.Error(),
+		Detail:   detail,
+	}}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestErrorDiags(t *testing.T) {
+	if diags := errorDiags(nil); diags != nil {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+
+	diags := errorDiags(errors.New("plain error"))
+	if len(diags) != 1 || diags[0].Summary != "plain error" || diags[0].Detail != "" {
+		t.Errorf("unexpected diagnostics for a plain error: %v", diags)
+	}
+
+	cases := []struct {
+		code    string
+		message string
+		hint    string
+	}{
+		{code: errCodeValidation, message: "invalid key", hint: "key attribute types"},
+		{code: errCodeValidation, message: "KMS key is inaccessible", hint: kmsHint},
+		{code: "KMSDisabledException", message: "disabled", hint: kmsHint},
+		{code: dynamodb.ErrCodeLimitExceededException, message: "too many", hint: "-parallelism"},
+		{code: dynamodb.ErrCodeResourceInUseException, message: "in use", hint: "pending change"},
+		{code: errCodeAccessDenied, message: "denied", hint: "dynamodb:UpdateTable"},
+	}
+
+	for _, tc := range cases {
+		err := fmt.Errorf("error creating DynamoDB GSI: %w", awserr.NewRequestFailure(awserr.New(tc.code, tc.message, nil), 400, "request-id"))
+		diags := errorDiags(err)
+		if len(diags) != 1 || diags[0].Severity != diag.Error {
+			t.Fatalf("%s: unexpected diagnostics %v", tc.code, diags)
+		}
+
+		d := diags[0]
+		if d.Summary != err.Error() {
+			t.Errorf("%s: unexpected summary %s", tc.code, d.Summary)
+		}
+		for _, s := range []string{tc.code, "request-id", tc.hint} {
+			if !strings.Contains(d.Detail, s) {
+				t.Errorf("%s: expected %q in detail %q", tc.code, s, d.Detail)
+			}
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
@@ -21,129 +27,437 @@ const (
 	deleteGSITimeout = 10 * time.Minute
 )
 
+// Policies to adopt an index which already exists when creating the resource.
+const (
+	adoptExistingNever       = "never"
+	adoptExistingIfIdentical = "if_identical"
+	adoptExistingAlways      = "always"
+)
+
+// Actions taken on an index whose creation failed.
+const (
+	onCreateFailureKeep   = "keep"
+	onCreateFailureDelete = "delete"
+)
+
 func dynamoDBGSIResource() *schema.Resource {
 	return &schema.Resource{
-		Schema: map[string]*schema.Schema{
-			"arn": {
-				Type:        schema.TypeString,
-				Computed:    true,
-				Description: "ARN of the Global Secondary Index.",
-			},
-			"table_name": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "Name of the DynamoDB table to which the GSI is associated..",
-			},
-			"name": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "Name of the index.",
-			},
-			"non_key_attributes": {
-				Type:        schema.TypeSet,
-				Optional:    true,
-				Elem:        &schema.Schema{Type: schema.TypeString},
-				ForceNew:    true,
-				Description: "Additional attributes to include based in the projection.",
-			},
-			"projection_type": {
-				Type:         schema.TypeString,
-				Required:     true,
-				ValidateFunc: stringInSlice(dynamodb.ProjectionType_Values(), false),
-				ForceNew:     true,
-				Description:  "Projection type.",
-			},
-			"hash_key": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "Hash key of the index.",
-			},
-			"range_key": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				ForceNew:    true,
-				Description: "Range key of the index.",
+		Schema:        dynamoDBGSISchema(),
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    dynamoDBGSIResourceV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: upgradeDynamoDBGSIStateV0,
 			},
-			"hash_key_type": {
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "Type of the hash key.",
+		},
+		CustomizeDiff: customdiff.Sequence(
+			customizeDiffCapacityFromTable,
+			customizeDiffPayPerRequestCapacity,
+			customizeDiffCapacityDecrease,
+			customizeDiffBackfillEstimate,
+		),
+		CreateContext: dynamoDBGSICreate,
+		ReadContext:   dynamoDBGSIRead,
+		UpdateContext: dynamoDBGSIUpdate,
+		DeleteContext: dynamoDBGSIDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(createGSITimeout),
+			Update: schema.DefaultTimeout(updateGSITimeout),
+			Delete: schema.DefaultTimeout(deleteGSITimeout),
+		},
+	}
+}
+
+// dynamoDBGSIResourceV0 is the resource before autoscaling_enabled was split in read and write.
+func dynamoDBGSIResourceV0() *schema.Resource {
+	s := dynamoDBGSISchema()
+	delete(s, "read_autoscaling_enabled")
+	delete(s, "write_autoscaling_enabled")
+	s["autoscaling_enabled"] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+	}
+
+	return &schema.Resource{
+		Schema: s,
+	}
+}
+
+func upgradeDynamoDBGSIStateV0(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	enabled, _ := rawState["autoscaling_enabled"].(bool)
+	rawState["read_autoscaling_enabled"] = enabled
+	rawState["write_autoscaling_enabled"] = enabled
+	delete(rawState, "autoscaling_enabled")
+
+	return rawState, nil
+}
+
+func dynamoDBGSISchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"arn": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "ARN of the Global Secondary Index.",
+		},
+		"table_name": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Name or ARN of the DynamoDB table to which the GSI is associated.",
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return sameTableName(old, new, d.Get("region").(string))
 			},
-			"range_key_type": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				ForceNew:    true,
-				Description: "Type of the range key.",
+		},
+		"table_id": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Unique identifier of the table, an index on a re-created table with the same name is considered gone.",
+		},
+		"table_creation_time": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Creation time of the table, used to detect the re-creation of tables without identifier.",
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: "Region of the DynamoDB table, defaults to the region of the table ARN or the provider region.",
+		},
+		"name": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			ForceNew:     true,
+			ExactlyOneOf: []string{"name", "name_prefix"},
+			Description:  "Name of the index, generated from name_prefix if not set.",
+		},
+		"name_prefix": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ExactlyOneOf: []string{"name", "name_prefix"},
+			ValidateFunc: validation.StringLenBetween(1, 255-resource.UniqueIDSuffixLength),
+			Description:  "Create the index with a unique name beginning with this prefix, so that it can be replaced with create_before_destroy.",
+		},
+		"non_key_attributes": {
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			ForceNew:    true,
+			Description: "Additional attributes to include based in the projection.",
+		},
+		"projection_type": {
+			Type:         schema.TypeString,
+			Required:     true,
+			ValidateFunc: stringInSlice(dynamodb.ProjectionType_Values(), false),
+			ForceNew:     true,
+			Description:  "Projection type.",
+		},
+		"hash_key": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Hash key of the index.",
+		},
+		"range_key": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Range key of the index.",
+		},
+		"hash_key_type": {
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Type of the hash key.",
+		},
+		"range_key_type": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Type of the range key.",
+		},
+		"billing_mode": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: stringInSlice(dynamodb.BillingMode_Values(), false),
+			Default:      dynamodb.BillingModeProvisioned,
+			Description:  "The billing mode to apply to this index. Should match the associated table",
+		},
+		"switch_table_billing_mode": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Switch the billing mode of the table when it does not match billing_mode, in the same UpdateTable call as the indexes of the table switching in the same apply.",
+		},
+		"table_read_capacity": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "Read capacity of the table when switch_table_billing_mode switches it to PROVISIONED.",
+		},
+		"table_write_capacity": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "Write capacity of the table when switch_table_billing_mode switches it to PROVISIONED.",
+		},
+		"read_capacity": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			Description: "Read capacity for the index, untracked after creation if read autoscaling is enabled. Computed from the table if read_capacity_ratio is set.",
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return old != "" && (d.Get("read_autoscaling_enabled").(bool) || d.Get("read_autoscaling_detected").(bool))
 			},
-			"billing_mode": {
-				Type:         schema.TypeString,
-				Optional:     true,
-				ValidateFunc: stringInSlice(dynamodb.BillingMode_Values(), false),
-				Default:      dynamodb.BillingModeProvisioned,
-				Description:  "The billing mode to apply to this index. Should match the associated table",
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+		"write_capacity": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Computed:    true,
+			Description: "Write capacity for the table, untracked after creation if write autoscaling is enabled. Computed from the table if write_capacity_ratio is set.",
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return old != "" && (d.Get("write_autoscaling_enabled").(bool) || d.Get("write_autoscaling_detected").(bool))
 			},
-			"read_capacity": {
-				Type:        schema.TypeInt,
-				Optional:    true,
-				Description: "Read capacity for the index, untracked after creation if autoscaling is enabled.",
-				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
-					return old != "" && d.Get("autoscaling_enabled").(bool)
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+		"read_capacity_ratio": {
+			Type:          schema.TypeFloat,
+			Optional:      true,
+			ConflictsWith: []string{"read_capacity"},
+			ValidateFunc:  validation.FloatAtLeast(0),
+			Description:   "Set the read capacity of the index to this ratio of the read capacity of the table, rounded up.",
+		},
+		"write_capacity_ratio": {
+			Type:          schema.TypeFloat,
+			Optional:      true,
+			ConflictsWith: []string{"write_capacity"},
+			ValidateFunc:  validation.FloatAtLeast(0),
+			Description:   "Set the write capacity of the index to this ratio of the write capacity of the table, rounded up.",
+		},
+		"backfill_write_capacity": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+			Description:  "Write capacity of the index while it is backfilled on create, lowered to write_capacity in the same apply once the backfill completes.",
+		},
+		"estimated_backfill_duration": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Estimated duration of the backfill of the index, shown in the plan when the index is created or replaced and computed from the item count and size of the table and the write capacity. The create warns if it is a minute or more.",
+		},
+		"read_autoscaling_enabled": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Whether read capacity is controlled by an autoscaler.",
+			Default:     false,
+		},
+		"write_autoscaling_enabled": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Whether write capacity is controlled by an autoscaler.",
+			Default:     false,
+		},
+		"autoscaling_detected": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether an Application Auto Scaling target controls the read or write capacity of the index.",
+		},
+		"read_autoscaling_detected": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether an Application Auto Scaling target controls the read capacity of the index, its diffs are then suppressed.",
+		},
+		"write_autoscaling_detected": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether an Application Auto Scaling target controls the write capacity of the index, its diffs are then suppressed.",
+		},
+		"deletion_protection_enabled": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Prevent the index from being destroyed, defaults to the provider deletion_protection_enabled setting.",
+		},
+		"capacity_decrease_quota": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      capacityDecreaseQuotaFail,
+			ValidateFunc: stringInSlice([]string{capacityDecreaseQuotaFail, capacityDecreaseQuotaDefer, capacityDecreaseQuotaIgnore}, false),
+			Description:  "What to do when a capacity decrease would exceed the daily quota of DynamoDB: fail the plan, defer the decrease by keeping the current capacity until it is allowed, or ignore the quota and let DynamoDB reject the update.",
+		},
+		"deregister_autoscaling_on_destroy": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Delete the Application Auto Scaling policies and deregister the scalable targets of the index once it is destroyed, defaults to true if read or write autoscaling is enabled or detected.",
+		},
+		"adopt_existing": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: stringInSlice([]string{adoptExistingNever, adoptExistingIfIdentical, adoptExistingAlways}, false),
+			Description:  "Whether to adopt an index with the same name which already exists on create: never, if_identical (same keys and projection) or always. Defaults to always if auto_import is set on the provider, never otherwise.",
+		},
+		"preflight_scan": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Scan the table before creating the index and report the items whose key attributes are missing or of the wrong type.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"segments": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      defaultScanSegments,
+						ValidateFunc: validation.IntBetween(1, 1000),
+						Description:  "Number of segments of the table scanned in parallel.",
+					},
+					"sample_size": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      0,
+						ValidateFunc: validation.IntAtLeast(0),
+						Description:  "Maximum number of items scanned, the whole table is scanned if 0.",
+					},
+					"fail_on_mismatch": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     false,
+						Description: "Fail the create if items have a key attribute of the wrong type, which would make the backfill fail.",
+					},
 				},
-				ValidateFunc: validation.IntAtLeast(0),
 			},
-			"write_capacity": {
-				Type:        schema.TypeInt,
-				Optional:    true,
-				Description: "Write capacity for the table, untracked after creation if autoscaling is enabled.",
-				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
-					return old != "" && d.Get("autoscaling_enabled").(bool)
+		},
+		"verify_after_create": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Once the index is backfilled, compare its number of items with the number of table items which have every key attribute.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"segments": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Default:      defaultScanSegments,
+						ValidateFunc: validation.IntBetween(1, 1000),
+						Description:  "Number of segments of the table and index scanned in parallel.",
+					},
+					"tolerance": {
+						Type:         schema.TypeFloat,
+						Optional:     true,
+						Default:      0,
+						ValidateFunc: validation.FloatBetween(0, 1),
+						Description:  "Difference allowed between the counts, as a fraction of the number of table items, to account for writes during the verification.",
+					},
+					"fail_on_mismatch": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     false,
+						Description: "Fail the create if the counts differ beyond the tolerance, the index is then handled according to on_create_failure.",
+					},
 				},
-				ValidateFunc: validation.IntAtLeast(0),
-			},
-			"autoscaling_enabled": {
-				Type:        schema.TypeBool,
-				Optional:    true,
-				Description: "Whether capacity is controlled by an autoscaler.",
-				Default:     false,
 			},
 		},
-		Create: dynamoDBGSICreate,
-		Read:   dynamoDBGSIRead,
-		Update: dynamoDBGSIUpdate,
-		Delete: dynamoDBGSIDelete,
-		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+		"on_create_failure": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      onCreateFailureKeep,
+			ValidateFunc: stringInSlice([]string{onCreateFailureKeep, onCreateFailureDelete}, false),
+			Description:  "What to do with the index if its creation fails: keep it as a tainted resource or delete it. The create waits for the backfill with delete so that a failed backfill is rolled back.",
 		},
 	}
 }
 
-func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
+func dynamoDBGSICreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	p := m.(*GSIProvider)
-	tn := d.Get("table_name").(string)
 	in := d.Get("name").(string)
+	if v, ok := d.GetOk("name_prefix"); ok {
+		in = resource.PrefixedUniqueId(v.(string))
+	}
+	region, tn, err := resolveTable(d)
+	if err != nil {
+		return errorDiags(err)
+	}
+	c := p.client(region)
+
+	adopt := adoptExistingNever
+	if v, ok := d.GetOk("adopt_existing"); ok {
+		adopt = v.(string)
+	} else if p.autoImport {
+		adopt = adoptExistingAlways
+	}
 
-	if d.IsNewResource() && p.autoImport {
-		// If auto-import is enabled, we just capture the current state and a drift should be
-		// expected of the next plan if the imported state is different from that of this GSI.
-		found, err := readGSI(d, p.c, tn, in)
+	if d.IsNewResource() {
+		t, i, err := describeGSI(ctx, c, tn, in)
 		if err != nil {
-			return err
+			return errorDiags(err)
+		}
+
+		// A previous create may have been interrupted before the GSI was recorded in the state,
+		// pick it up where it was left if it is the same index, whatever adopt_existing is. Unlike
+		// adopting, this may update or delete the index.
+		if i != nil && aws.StringValue(i.IndexStatus) == dynamodb.IndexStatusCreating && len(diffExistingGSI(d, t, i)) == 0 {
+			if err = p.checkWritable("create", tn, in); err != nil {
+				return errorDiags(err)
+			}
+
+			log.Printf("[INFO] Dynamodb Table GSI (%s) is still being created, resuming wait", in)
+			d.SetId(namesToID(region, tn, in))
+
+			i, err = waitDynamoDBGSICreated(ctx, d, c, tn, in)
+			if err != nil {
+				return errorDiags(onCreateFailure(d, c, tn, in, fmt.Errorf("error waiting for DynamoDB GSI (%s) creation on table %s: %w", in, tn, err)))
+			}
+
+			diags := finishCreate(ctx, d, c, tn, in, i)
+			warnings, err := verifyCreate(ctx, d, c, tn, in, indexKeys(d))
+			if err != nil {
+				return append(diags, errorDiags(onCreateFailure(d, c, tn, in, err))...)
+			}
+			return append(append(diags, warnings...), dynamoDBGSIRead(ctx, d, m)...)
 		}
 
-		if found {
-			d.SetId(fmt.Sprintf("%s:%s", tn, in))
+		// When adopting, we just capture the current state and a drift should be expected of the
+		// next plan if the capacity of the existing GSI is different from that of this GSI.
+		if i != nil && adopt != adoptExistingNever {
+			if adopt == adoptExistingIfIdentical {
+				if diffs := diffExistingGSI(d, t, i); len(diffs) > 0 {
+					return diag.Diagnostics{{
+						Severity: diag.Error,
+						Summary:  fmt.Sprintf("existing DynamoDB GSI (%s) on table %s differs from the configuration and cannot be adopted", in, tn),
+						Detail:   strings.Join(diffs, "\n"),
+					}}
+				}
+			}
+
+			if err = flattenGSI(d, t, i); err != nil {
+				return errorDiags(err)
+			}
+			targets, err := p.scalableTargets(ctx, c, tn, in)
+			if err != nil {
+				return errorDiags(err)
+			}
+			flattenScalableTargets(d, targets)
+			d.SetId(namesToID(region, tn, in))
+			d.Set("region", p.resolvedRegion(region))
 			log.Printf("[INFO] Dynamodb Table GSI (%s) automatically imported", d.Get("name").(string))
-			return nil
+			return scalableTargetWarnings(d, targets, tn, in)
 		}
 	}
 
-	ad, err := getAttributeDefinition(p.c, tn)
+	if err = p.checkWritable("create", tn, in); err != nil {
+		return errorDiags(err)
+	}
+
+	ad, err := getAttributeDefinition(ctx, c, tn)
 	if err != nil {
-		return err
+		return errorDiags(err)
 	}
 
 	hType := d.Get("hash_key_type")
@@ -154,7 +468,7 @@ func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
 			AttributeType: aws.String(hType.(string)),
 		})
 	} else if rhType != hType {
-		return errors.New("hash key type does not match the existing definition on the table")
+		return diag.Errorf("hash key type does not match the existing definition on the table")
 	}
 
 	keySchema := []*dynamodb.KeySchemaElement{
@@ -167,7 +481,7 @@ func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
 	if r, ok := d.GetOk("range_key"); ok {
 		rType, e := d.GetOkExists("range_key_type")
 		if !e {
-			return errors.New("missing range_key_type")
+			return diag.Errorf("missing range_key_type")
 		}
 		rrType := getAttributeType(ad, aws.String(r.(string)))
 		if rrType == "" {
@@ -176,7 +490,7 @@ func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
 				AttributeType: aws.String(rType.(string)),
 			})
 		} else if rType != rrType {
-			return errors.New("range key type does not match the existing definition on the table")
+			return diag.Errorf("range key type does not match the existing definition on the table")
 		}
 
 		keySchema = append(keySchema, &dynamodb.KeySchemaElement{
@@ -197,8 +511,11 @@ func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
 		}
 	}
 
+	if err = resolveCapacityRatios(ctx, d, c, tn); err != nil {
+		return errorDiags(err)
+	}
 	if err = validateBillingMode(d); err != nil {
-		return err
+		return errorDiags(err)
 	}
 
 	input := dynamodb.UpdateTableInput{
@@ -220,26 +537,135 @@ func dynamoDBGSICreate(d *schema.ResourceData, m interface{}) error {
 			ReadCapacityUnits:  aws.Int64(int64(d.Get("read_capacity").(int))),
 			WriteCapacityUnits: aws.Int64(int64(d.Get("write_capacity").(int))),
 		}
+		if v, ok := d.GetOk("backfill_write_capacity"); ok {
+			input.GlobalSecondaryIndexUpdates[0].Create.ProvisionedThroughput.WriteCapacityUnits = aws.Int64(int64(v.(int)))
+		}
+	}
+
+	keys := indexKeys(d)
+	diags := preflightDiags(ctx, d, c, tn, in, keys)
+	if diags.HasError() {
+		return diags
+	}
+
+	_, err = c.updateTable(ctx, &input)
+	if err != nil {
+		return append(diags, errorDiags(fmt.Errorf("error creating DynamoDB GSI (%s) on table %s: %w", in, tn, err))...)
+	}
+
+	// Record the index as soon as it exists so that a failed wait leaves a tainted resource in the
+	// state rather than an index unknown to Terraform.
+	d.SetId(namesToID(region, tn, in))
+	diags = append(diags, backfillEstimateWarning(d, tn, in)...)
+
+	i, err := waitDynamoDBGSICreated(ctx, d, c, tn, in)
+	if err != nil {
+		return append(diags, errorDiags(onCreateFailure(d, c, tn, in, fmt.Errorf("error waiting for DynamoDB GSI (%s) creation on table %s: %w", in, tn, err)))...)
+	}
+
+	diags = append(diags, finishCreate(ctx, d, c, tn, in, i)...)
+	warnings, err := verifyCreate(ctx, d, c, tn, in, keys)
+	if err != nil {
+		return append(diags, errorDiags(onCreateFailure(d, c, tn, in, err))...)
+	}
+	diags = append(diags, warnings...)
+	return append(diags, dynamoDBGSIRead(ctx, d, m)...)
+}
+
+// indexKeys returns the types of the key attributes of the index by name.
+func indexKeys(d resourceGetter) map[string]string {
+	keys := map[string]string{d.Get("hash_key").(string): d.Get("hash_key_type").(string)}
+	if r, ok := d.GetOk("range_key"); ok {
+		keys[r.(string)] = d.Get("range_key_type").(string)
+	}
+	return keys
+}
+
+// finishCreate lowers the write capacity of an index created with backfill_write_capacity once it is
+// backfilled, other indexes are not waited for.
+func finishCreate(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string, in string, i *dynamodb.GlobalSecondaryIndexDescription) diag.Diagnostics {
+	if _, ok := d.GetOk("backfill_write_capacity"); !ok || d.Get("billing_mode") != dynamodb.BillingModeProvisioned {
+		return backfillWarning(i, tn)
+	}
+
+	// The index is usable at this point, failing to lower its capacity must not taint it. The next
+	// plan shows the difference with write_capacity.
+	warning := func(err error) diag.Diagnostics {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("write capacity of DynamoDB GSI (%s) on table %s was not lowered after the backfill", in, tn),
+			Detail:   fmt.Sprintf("%s\n\nThe index keeps its backfill_write_capacity until the next apply.", err),
+		}}
 	}
 
-	_, err = p.c.UpdateTable(&input)
+	i, err := waitDynamoDBGSIBackfilled(ctx, c, tn, in, d.Timeout(schema.TimeoutCreate))
 	if err != nil {
-		return fmt.Errorf("error creating DynamoDB GSI (%s) on table %s: %w", in, tn, err)
+		return warning(fmt.Errorf("error waiting for DynamoDB GSI (%s) backfill on table %s: %w", in, tn, err))
+	}
+
+	live := i.ProvisionedThroughput
+	writeCapacity := int64(d.Get("write_capacity").(int))
+	if live == nil || aws.Int64Value(live.WriteCapacityUnits) == writeCapacity {
+		return nil
+	}
+
+	now := time.Now()
+	if aws.Int64Value(live.WriteCapacityUnits) > writeCapacity {
+		if next := nextDecrease(live, now); next.After(now) {
+			return warning(decreaseQuotaError([]string{"write_capacity"}, in, tn, next))
+		}
+	}
+
+	update := &dynamodb.UpdateGlobalSecondaryIndexAction{
+		IndexName: aws.String(in),
+		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  live.ReadCapacityUnits,
+			WriteCapacityUnits: aws.Int64(writeCapacity),
+		},
+	}
+	if err := c.updateGSI(ctx, tn, &tableUpdate{index: update}); err != nil {
+		return warning(fmt.Errorf("error updating DynamoDB GSI (%s) on table %s: %w", in, tn, err))
 	}
+	if _, err := waitDynamoDBGSIActive(ctx, c, tn, in, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return warning(fmt.Errorf("error waiting for DynamoDB GSI (%s) update on table %s: %w", in, tn, err))
+	}
+
+	return nil
+}
 
-	if err = waitDynamoDBGSIActive(p.c, tn, in); err != nil {
-		return err
+// backfillWarning warns that an index is not usable yet since the provider does not wait for the
+// backfill to complete.
+func backfillWarning(i *dynamodb.GlobalSecondaryIndexDescription, tn string) diag.Diagnostics {
+	if i == nil || aws.StringValue(i.IndexStatus) != dynamodb.IndexStatusCreating {
+		return nil
 	}
 
-	if d.Get("autoscaling_enabled").(bool) {
-		// Don't persist the capacity in the state if it is managed by an autoscaler.
-		d.Set("read_capacity", nil)
-		d.Set("write_capacity", nil)
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("DynamoDB GSI (%s) on table %s is still backfilling", aws.StringValue(i.IndexName), tn),
+		Detail:   "The index cannot be queried until the backfill completes and its status is ACTIVE.",
+	}}
+}
+
+// onCreateFailure deletes the index if its creation failed and on_create_failure = "delete", the
+// original error is returned in every case.
+func onCreateFailure(d *schema.ResourceData, c *dynamoDBClient, tn string, in string, cause error) error {
+	if d.Get("on_create_failure").(string) != onCreateFailureDelete {
+		return cause
 	}
 
-	d.SetId(fmt.Sprintf("%s:%s", tn, in))
+	// The create context has expired if the wait timed out, or is canceled on interrupt, the deletion
+	// gets its own context bounded by the delete timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutDelete))
+	defer cancel()
 
-	return dynamoDBGSIRead(d, m)
+	log.Printf("[WARN] Deleting Dynamodb Table GSI %s on table %s after failed creation: %s", in, tn, cause)
+	if err := deleteGSI(ctx, c, tn, in, d.Timeout(schema.TimeoutDelete)); err != nil {
+		return fmt.Errorf("%w (the index could not be deleted: %s)", cause, err)
+	}
+
+	d.SetId("")
+	return cause
 }
 
 func validateBillingMode(d *schema.ResourceData) error {
@@ -249,7 +675,13 @@ func validateBillingMode(d *schema.ResourceData) error {
 	case dynamodb.BillingModePayPerRequest:
 		if readCapacity != 0 || writCapacity != 0 {
 			return errors.New("read_capacity / write_capacity must not be set for billing_mode = PAY_PER_REQUEST")
-		} else if d.Get("autoscaling_enabled").(bool) {
+		} else if _, ok := d.GetOk("read_capacity_ratio"); ok {
+			return errors.New("read_capacity_ratio / write_capacity_ratio must not be set for billing_mode = PAY_PER_REQUEST")
+		} else if _, ok := d.GetOk("write_capacity_ratio"); ok {
+			return errors.New("read_capacity_ratio / write_capacity_ratio must not be set for billing_mode = PAY_PER_REQUEST")
+		} else if _, ok := d.GetOk("backfill_write_capacity"); ok {
+			return errors.New("backfill_write_capacity must not be set for billing_mode = PAY_PER_REQUEST")
+		} else if d.Get("read_autoscaling_enabled").(bool) || d.Get("write_autoscaling_enabled").(bool) {
 			return errors.New("autoscaling cannot be enabled with billing_mode = PAY_PER_REQUEST")
 		}
 	case dynamodb.BillingModeProvisioned:
@@ -260,47 +692,132 @@ func validateBillingMode(d *schema.ResourceData) error {
 	return nil
 }
 
-func getAttributeDefinition(c *dynamodb.DynamoDB, tn string) ([]*dynamodb.AttributeDefinition, error) {
-	t, err := c.DescribeTable(&dynamodb.DescribeTableInput{
-		TableName: aws.String(tn),
-	})
+func getAttributeDefinition(ctx context.Context, c *dynamoDBClient, tn string) ([]*dynamodb.AttributeDefinition, error) {
+	t, err := c.describeTable(ctx, tn)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Dynamodb Table (%s): %w", tn, err)
+	}
+
+	// The description is shared, copy the definitions since they get extended with the index keys.
+	return append([]*dynamodb.AttributeDefinition{}, t.AttributeDefinitions...), nil
+}
+
+// resourceGetter reads the attributes of a resource from either its data or its diff.
+type resourceGetter interface {
+	Get(key string) interface{}
+	GetOk(key string) (interface{}, bool)
+}
+
+// resolveTable returns the region and the name of the table the GSI belongs to. The region is empty
+// unless set on the resource or through a table ARN, in which case the provider region is used.
+func resolveTable(d resourceGetter) (string, string, error) {
+	tn := d.Get("table_name").(string)
+	region := ""
+	if v, ok := d.GetOk("region"); ok {
+		region = v.(string)
+	}
+
+	if !arn.IsARN(tn) {
+		return region, tn, nil
+	}
+
+	a, err := arn.Parse(tn)
 	if err != nil {
-		return nil, err
+		return "", "", fmt.Errorf("invalid table ARN (%s): %w", tn, err)
+	}
+	if a.Service != dynamodb.EndpointsID || !strings.HasPrefix(a.Resource, "table/") {
+		return "", "", fmt.Errorf("invalid table ARN (%s): not a DynamoDB table", tn)
+	}
+	if region != "" && region != a.Region {
+		return "", "", fmt.Errorf("region %s does not match the region of the table ARN (%s)", region, tn)
 	}
 
-	return t.Table.AttributeDefinitions, nil
+	return a.Region, strings.TrimPrefix(a.Resource, "table/"), nil
 }
 
-func idToNames(id string) (string, string, error) {
-	// Convert the GSI name to (table_name, index_name).
-	splits := strings.SplitN(id, ":", 2)
-	if len(splits) != 2 {
-		return "", "", fmt.Errorf("invalid DynamoDB GSI ID (%s)", id)
+// sameTableName returns whether a table ARN and a table name designate the same table, an imported
+// index only has the name of its table in the state.
+func sameTableName(old string, new string, region string) bool {
+	tn, a := old, new
+	if arn.IsARN(old) {
+		tn, a = new, old
+	}
+	if arn.IsARN(tn) || !arn.IsARN(a) {
+		return false
+	}
+
+	parsed, err := arn.Parse(a)
+	if err != nil || parsed.Service != dynamodb.EndpointsID || !strings.HasPrefix(parsed.Resource, "table/") {
+		return false
 	}
-	return splits[0], splits[1], nil
+
+	return tn == strings.TrimPrefix(parsed.Resource, "table/") && (region == "" || region == parsed.Region)
 }
 
-func dynamoDBGSIRead(d *schema.ResourceData, m interface{}) error {
-	c := m.(*GSIProvider).c
-	tn, in, err := idToNames(d.Id())
+func namesToID(region string, tn string, in string) string {
+	if region == "" {
+		return fmt.Sprintf("%s:%s", tn, in)
+	}
+	return fmt.Sprintf("%s:%s:%s", region, tn, in)
+}
+
+func idToNames(id string) (string, string, string, error) {
+	// Convert the GSI ID to (region, table_name, index_name), the region is only part of the ID if
+	// it was set on the resource and is empty otherwise.
+	splits := strings.Split(id, ":")
+	for _, s := range splits {
+		if s == "" {
+			return "", "", "", fmt.Errorf("invalid DynamoDB GSI ID (%s)", id)
+		}
+	}
+
+	switch len(splits) {
+	case 2:
+		return "", splits[0], splits[1], nil
+	case 3:
+		return splits[0], splits[1], splits[2], nil
+	default:
+		return "", "", "", fmt.Errorf("invalid DynamoDB GSI ID (%s)", id)
+	}
+}
+
+func dynamoDBGSIRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	p := m.(*GSIProvider)
+	region, tn, in, err := idToNames(d.Id())
 
 	if err != nil {
-		return err
+		return errorDiags(err)
 	}
 
-	found, err := readGSI(d, c, tn, in)
+	c := p.client(region)
+	d.Set("region", p.resolvedRegion(region))
+
+	found, err := readGSI(ctx, d, c, tn, in)
 	if !found {
+		if err != nil {
+			return errorDiags(err)
+		}
+
 		if !d.IsNewResource() {
 			log.Printf("[WARN] Dynamodb Table GSI (%s) not found, removing from state", d.Id())
 			d.SetId("")
 			return nil
 		}
 
-		return fmt.Errorf("dynamodb table (%s) or GSI not found (%s)", tn, in)
+		return diag.Errorf("dynamodb table (%s) or GSI not found (%s)", tn, in)
 
 	}
+	if err != nil {
+		return errorDiags(err)
+	}
 
-	return err
+	targets, err := p.scalableTargets(ctx, c, tn, in)
+	if err != nil {
+		return errorDiags(err)
+	}
+	flattenScalableTargets(d, targets)
+
+	return nil
 }
 
 func getAttributeType(ad []*dynamodb.AttributeDefinition, n *string) string {
@@ -312,8 +829,8 @@ func getAttributeType(ad []*dynamodb.AttributeDefinition, n *string) string {
 	return ""
 }
 
-func readGSI(d *schema.ResourceData, c *dynamodb.DynamoDB, tn string, in string) (bool, error) {
-	t, i, err := describeGSI(c, tn, in)
+func readGSI(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string, in string) (bool, error) {
+	t, i, err := describeGSI(ctx, c, tn, in)
 	if err != nil {
 		return false, err
 	}
@@ -322,9 +839,40 @@ func readGSI(d *schema.ResourceData, c *dynamodb.DynamoDB, tn string, in string)
 		return false, nil
 	}
 
+	// The index in the state belongs to a table which was destroyed, an index with the same name on
+	// the new table is a different index.
+	if !sameTable(d, t) {
+		log.Printf("[WARN] Dynamodb Table (%s) was re-created since the GSI (%s) was read", tn, in)
+		return false, nil
+	}
+
+	return true, flattenGSI(d, t, i)
+}
+
+// sameTable returns whether a table is the one recorded in the state, the tables recorded before their
+// identity was stored are assumed to be the same.
+func sameTable(d *schema.ResourceData, t *dynamodb.TableDescription) bool {
+	if id := d.Get("table_id").(string); id != "" && aws.StringValue(t.TableId) != "" {
+		return id == aws.StringValue(t.TableId)
+	}
+	if created := d.Get("table_creation_time").(string); created != "" && t.CreationDateTime != nil {
+		return created == t.CreationDateTime.UTC().Format(time.RFC3339)
+	}
+	return true
+}
+
+func flattenGSI(d *schema.ResourceData, t *dynamodb.TableDescription, i *dynamodb.GlobalSecondaryIndexDescription) error {
 	d.Set("arn", i.IndexArn)
 	d.Set("name", i.IndexName)
-	d.Set("table_name", t.TableName)
+	d.Set("billing_mode", tableBillingMode(t))
+	d.Set("table_id", t.TableId)
+	if t.CreationDateTime != nil {
+		d.Set("table_creation_time", t.CreationDateTime.UTC().Format(time.RFC3339))
+	}
+	// Keep the table ARN in the state if the resource is configured with one.
+	if !arn.IsARN(d.Get("table_name").(string)) {
+		d.Set("table_name", t.TableName)
+	}
 
 	// Since readGSI can be used on an import on create, we need to erase the optional values from the
 	// state or we will end up with writing a state that is the expected one rather than the applied one
@@ -337,7 +885,7 @@ func readGSI(d *schema.ResourceData, c *dynamodb.DynamoDB, tn string, in string)
 	for _, attribute := range i.KeySchema {
 		attrType := getAttributeType(t.AttributeDefinitions, attribute.AttributeName)
 		if attrType == "" {
-			return true, fmt.Errorf("attribute %s not defined on table", *attribute.AttributeName)
+			return fmt.Errorf("attribute %s not defined on table", *attribute.AttributeName)
 		}
 
 		if aws.StringValue(attribute.KeyType) == dynamodb.KeyTypeHash {
@@ -356,73 +904,277 @@ func readGSI(d *schema.ResourceData, c *dynamodb.DynamoDB, tn string, in string)
 		d.Set("non_key_attributes", aws.StringValueSlice(i.Projection.NonKeyAttributes))
 	}
 
+	// The live throughput is recorded even for the dimensions controlled by an autoscaler, their diffs
+	// are suppressed until autoscaling is turned off and the plan then goes from the live throughput to
+	// the configured one.
 	if i.ProvisionedThroughput != nil {
 		d.Set("read_capacity", i.ProvisionedThroughput.ReadCapacityUnits)
 		d.Set("write_capacity", i.ProvisionedThroughput.WriteCapacityUnits)
 	}
 
-	return true, nil
+	return nil
+}
+
+// diffExistingGSI lists the differences between the key schema and projection of an existing GSI and
+// the configured ones.
+func diffExistingGSI(d *schema.ResourceData, t *dynamodb.TableDescription, i *dynamodb.GlobalSecondaryIndexDescription) []string {
+	existing := map[string]string{}
+	for _, attribute := range i.KeySchema {
+		attrType := getAttributeType(t.AttributeDefinitions, attribute.AttributeName)
+		switch aws.StringValue(attribute.KeyType) {
+		case dynamodb.KeyTypeHash:
+			existing["hash_key"] = aws.StringValue(attribute.AttributeName)
+			existing["hash_key_type"] = attrType
+		case dynamodb.KeyTypeRange:
+			existing["range_key"] = aws.StringValue(attribute.AttributeName)
+			existing["range_key_type"] = attrType
+		}
+	}
+
+	nka := []string{}
+	if i.Projection != nil {
+		existing["projection_type"] = aws.StringValue(i.Projection.ProjectionType)
+		nka = aws.StringValueSlice(i.Projection.NonKeyAttributes)
+	}
+
+	diffs := []string{}
+	for _, attr := range []string{"hash_key", "hash_key_type", "range_key", "range_key_type", "projection_type"} {
+		if v := d.Get(attr).(string); v != existing[attr] {
+			diffs = append(diffs, fmt.Sprintf("%s: existing %q, configured %q", attr, existing[attr], v))
+		}
+	}
+
+	configured := []string{}
+	for _, a := range d.Get("non_key_attributes").(*schema.Set).List() {
+		configured = append(configured, a.(string))
+	}
+	sort.Strings(configured)
+	sort.Strings(nka)
+	if strings.Join(configured, ",") != strings.Join(nka, ",") {
+		diffs = append(diffs, fmt.Sprintf("non_key_attributes: existing %q, configured %q", nka, configured))
+	}
+
+	return diffs
 }
 
-func dynamoDBGSIUpdate(d *schema.ResourceData, m interface{}) error {
-	c := m.(*GSIProvider).c
-	tn, in, err := idToNames(d.Id())
+func dynamoDBGSIUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	p := m.(*GSIProvider)
+	region, tn, in, err := idToNames(d.Id())
 
 	if err != nil {
-		return err
+		return errorDiags(err)
 	}
 
+	c := p.client(region)
+
+	if err = p.checkWritable("update", tn, in); err != nil {
+		return errorDiags(err)
+	}
+
+	if err = resolveCapacityRatios(ctx, d, c, tn); err != nil {
+		return errorDiags(err)
+	}
 	if err = validateBillingMode(d); err != nil {
-		return err
+		return errorDiags(err)
+	}
+
+	// Compare with the live table rather than the state, an autoscaler may have been added or changed
+	// the throughput, or the table may have switched billing mode, since the last refresh.
+	t, i, err := describeGSI(ctx, c, tn, in)
+	if err != nil {
+		return errorDiags(err)
+	}
+	if i == nil {
+		return diag.Errorf("dynamodb table (%s) or GSI not found (%s)", tn, in)
 	}
 
-	if !d.Get("autoscaling_enabled").(bool) && d.Get("billing_mode") == dynamodb.BillingModeProvisioned {
-		update := &dynamodb.UpdateGlobalSecondaryIndexAction{
-			IndexName:             aws.String(in),
-			ProvisionedThroughput: &dynamodb.ProvisionedThroughput{},
+	update, err := billingModeUpdate(d, t, tn)
+	if err != nil {
+		return errorDiags(err)
+	}
+
+	var diags diag.Diagnostics
+	if d.Get("billing_mode") == dynamodb.BillingModeProvisioned {
+		targets, err := p.scalableTargets(ctx, c, tn, in)
+		if err != nil {
+			return errorDiags(err)
 		}
+		diags = scalableTargetWarnings(d, targets, tn, in)
 
-		changed := false
-		if d.HasChange("read_capacity") {
-			changed = true
-			update.ProvisionedThroughput.ReadCapacityUnits = aws.Int64(int64(d.Get("read_capacity").(int)))
+		// An index switching from PAY_PER_REQUEST has no throughput to keep.
+		live := i.ProvisionedThroughput
+		if update.billingMode != "" {
+			live = nil
 		}
-		if d.HasChange("write_capacity") {
-			changed = true
-			update.ProvisionedThroughput.WriteCapacityUnits = aws.Int64(int64(d.Get("write_capacity").(int)))
+		throughput := provisionedThroughputUpdate(d, targets, live)
+
+		// Check the decreases before sending the update, a rejected update would also fail the updates
+		// of the other indexes sent along with it.
+		policy := d.Get("capacity_decrease_quota").(string)
+		if decreased := decreasedDimensions(throughput, live); len(decreased) > 0 && policy != capacityDecreaseQuotaIgnore {
+			now := time.Now()
+			if next := nextDecrease(live, now); next.After(now) {
+				if policy == capacityDecreaseQuotaFail {
+					return append(diags, errorDiags(decreaseQuotaError(decreased, in, tn, next))...)
+				}
+
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  fmt.Sprintf("decrease of %s of DynamoDB GSI (%s) on table %s deferred", strings.Join(decreased, " and "), in, tn),
+					Detail:   fmt.Sprintf("The daily quota of capacity decreases is reached, the next decrease is allowed at %s. The current capacity is kept until then and the decrease is planned again.", next.Format(time.RFC3339)),
+				})
+				throughput = deferDecreases(throughput, live)
+			}
 		}
 
-		if changed {
-			if _, err := c.UpdateTable(&dynamodb.UpdateTableInput{
-				TableName: aws.String(tn),
-				GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
-					{
-						Update: update,
-					},
-				},
-			}); err != nil {
-				return err
+		if throughput != nil {
+			update.index = &dynamodb.UpdateGlobalSecondaryIndexAction{
+				IndexName:             aws.String(in),
+				ProvisionedThroughput: throughput,
 			}
+		}
+	}
 
-			if err := waitDynamoDBGSIActive(c, tn, in); err != nil {
-				return fmt.Errorf("error waiting for DynamoDB GSI (%s) update on table %s: %w", in, tn, err)
-			}
+	if update.index != nil || update.billingMode != "" {
+		// The update may be sent along with the updates of the other indexes of the table, which is
+		// required when the table switches to PROVISIONED since all its indexes need a throughput.
+		if err := c.updateGSI(ctx, tn, update); err != nil {
+			return append(diags, errorDiags(fmt.Errorf("error updating DynamoDB GSI (%s) on table %s: %w", in, tn, err))...)
+		}
+
+		if _, err := waitDynamoDBGSIActive(ctx, c, tn, in, d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return append(diags, errorDiags(fmt.Errorf("error waiting for DynamoDB GSI (%s) update on table %s: %w", in, tn, err))...)
+		}
+	}
+
+	return append(diags, dynamoDBGSIRead(ctx, d, m)...)
+}
+
+// tableBillingMode returns the billing mode of a table, tables which never switched mode may have no
+// billing mode summary and are PROVISIONED.
+func tableBillingMode(t *dynamodb.TableDescription) string {
+	if t.BillingModeSummary != nil && t.BillingModeSummary.BillingMode != nil {
+		return aws.StringValue(t.BillingModeSummary.BillingMode)
+	}
+	return dynamodb.BillingModeProvisioned
+}
+
+// billingModeUpdate returns the update switching the billing mode of the table when it does not match
+// the configured one. The indexes follow the billing mode of their table, so the table is only
+// switched if switch_table_billing_mode is set.
+func billingModeUpdate(d *schema.ResourceData, t *dynamodb.TableDescription, tn string) (*tableUpdate, error) {
+	mode := d.Get("billing_mode").(string)
+	current := tableBillingMode(t)
+	if mode == current {
+		return &tableUpdate{}, nil
+	}
+
+	if !d.Get("switch_table_billing_mode").(bool) {
+		return nil, fmt.Errorf("table %s is %s but billing_mode is %s: switch the table first or set switch_table_billing_mode = true to switch it along with its indexes", tn, current, mode)
+	}
+
+	update := &tableUpdate{billingMode: mode}
+	if mode == dynamodb.BillingModeProvisioned {
+		readCapacity := d.Get("table_read_capacity").(int)
+		writeCapacity := d.Get("table_write_capacity").(int)
+		if readCapacity == 0 || writeCapacity == 0 {
+			return nil, fmt.Errorf("table_read_capacity / table_write_capacity must be set to switch table %s to PROVISIONED", tn)
+		}
+		update.throughput = &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(int64(readCapacity)),
+			WriteCapacityUnits: aws.Int64(int64(writeCapacity)),
 		}
 	}
 
-	return dynamoDBGSIRead(d, m)
+	return update, nil
 }
 
-func dynamoDBGSIDelete(d *schema.ResourceData, m interface{}) error {
-	c := m.(*GSIProvider).c
-	tn, in, err := idToNames(d.Id())
+// provisionedThroughputUpdate returns the throughput to apply to an index, or nil if the live throughput
+// already matches the configuration. The dimensions controlled by an autoscaler keep their live value,
+// the others are set to the configured capacity, including those for which autoscaling was just turned
+// off so that they all go back to the configuration in a single update. An index without live
+// throughput gets the configured capacities.
+func provisionedThroughputUpdate(d *schema.ResourceData, targets map[string]*applicationautoscaling.ScalableTarget, live *dynamodb.ProvisionedThroughputDescription) *dynamodb.ProvisionedThroughput {
+	if live == nil {
+		return &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(int64(d.Get("read_capacity").(int))),
+			WriteCapacityUnits: aws.Int64(int64(d.Get("write_capacity").(int))),
+		}
+	}
+
+	liveRead := aws.Int64Value(live.ReadCapacityUnits)
+	liveWrite := aws.Int64Value(live.WriteCapacityUnits)
+
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(liveRead),
+		WriteCapacityUnits: aws.Int64(liveWrite),
+	}
+	if !autoscaled(d, targets, applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits) {
+		throughput.ReadCapacityUnits = aws.Int64(int64(d.Get("read_capacity").(int)))
+	}
+	if !autoscaled(d, targets, applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits) {
+		throughput.WriteCapacityUnits = aws.Int64(int64(d.Get("write_capacity").(int)))
+	}
+
+	if aws.Int64Value(throughput.ReadCapacityUnits) == liveRead && aws.Int64Value(throughput.WriteCapacityUnits) == liveWrite {
+		return nil
+	}
+	return throughput
+}
+
+func dynamoDBGSIDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	p := m.(*GSIProvider)
+	region, tn, in, err := idToNames(d.Id())
 	if err != nil {
-		return err
+		return errorDiags(err)
+	}
+
+	c := p.client(region)
+
+	if err = p.checkWritable("delete", tn, in); err != nil {
+		return errorDiags(err)
+	}
+
+	// The protection must be explicitly turned off, and applied, before the index can be destroyed.
+	protected := p.deletionProtection
+	if v, ok := d.GetOkExists("deletion_protection_enabled"); ok {
+		protected = v.(bool)
+	}
+	if protected {
+		return diag.Errorf("cannot delete GSI %s on table %s: deletion protection is enabled, set deletion_protection_enabled = false and apply before destroying it", in, tn)
 	}
 
 	log.Printf("[DEBUG] Deleting Dynamodb Table GSI %s on table %s", in, tn)
 
-	_, err = c.UpdateTable(&dynamodb.UpdateTableInput{
+	if err = deleteGSI(ctx, c, tn, in, d.Timeout(schema.TimeoutDelete)); err != nil {
+		return errorDiags(err)
+	}
+
+	// Leftover targets keep alarming and are picked up by an index re-created with the same name.
+	deregister := d.Get("read_autoscaling_enabled").(bool) || d.Get("write_autoscaling_enabled").(bool) || d.Get("autoscaling_detected").(bool)
+	if v, ok := d.GetOkExists("deregister_autoscaling_on_destroy"); ok {
+		deregister = v.(bool)
+	}
+	if !deregister {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Deregistering Application Auto Scaling targets of Dynamodb Table GSI %s on table %s", in, tn)
+
+	// The index is gone at this point, failing would keep a resource which cannot be destroyed again.
+	if err = c.deleteScalableTargets(ctx, tn, in); err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  err.Error(),
+			Detail:   "The index was deleted but its Application Auto Scaling policies and scalable targets were left behind, delete them before re-creating an index with the same name.",
+		}}
+	}
+
+	return nil
+}
+
+func deleteGSI(ctx context.Context, c *dynamoDBClient, tn string, in string, timeout time.Duration) error {
+	_, err := c.updateTable(ctx, &dynamodb.UpdateTableInput{
 		TableName: aws.String(tn),
 		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
 			{
@@ -437,20 +1189,18 @@ func dynamoDBGSIDelete(d *schema.ResourceData, m interface{}) error {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
 			return fmt.Errorf("dynamodb table %s or index %s does not exist", tn, in)
 		}
-		return fmt.Errorf("failed to delete GSI %s", in)
+		return fmt.Errorf("error deleting DynamoDB GSI (%s) on table %s: %w", in, tn, err)
 	}
 
-	if err := waitDynamoDBGSIDeleted(c, tn, in); err != nil {
+	if err := waitDynamoDBGSIDeleted(ctx, c, tn, in, timeout); err != nil {
 		return fmt.Errorf("error waiting for DynamoDB GSI (%s) deletion on table %s: %w", in, tn, err)
 	}
 
 	return nil
 }
 
-func describeGSI(c *dynamodb.DynamoDB, tn string, in string) (*dynamodb.TableDescription, *dynamodb.GlobalSecondaryIndexDescription, error) {
-	t, err := c.DescribeTable(&dynamodb.DescribeTableInput{
-		TableName: aws.String(tn),
-	})
+func describeGSI(ctx context.Context, c *dynamoDBClient, tn string, in string) (*dynamodb.TableDescription, *dynamodb.GlobalSecondaryIndexDescription, error) {
+	t, err := c.describeTable(ctx, tn)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
 			return nil, nil, nil
@@ -459,59 +1209,58 @@ func describeGSI(c *dynamodb.DynamoDB, tn string, in string) (*dynamodb.TableDes
 		return nil, nil, fmt.Errorf("error reading Dynamodb Table (%s): %w", tn, err)
 	}
 
-	for _, i := range t.Table.GlobalSecondaryIndexes {
+	for _, i := range t.GlobalSecondaryIndexes {
 		if *i.IndexName == in {
-			return t.Table, i, nil
+			return t, i, nil
 		}
 	}
 
 	return nil, nil, nil
 }
 
-func statusDynamoDBGSI(c *dynamodb.DynamoDB, tn string, in string) resource.StateRefreshFunc {
-	return func() (interface{}, string, error) {
-		_, i, err := describeGSI(c, tn, in)
-		if err != nil {
-			return nil, "", err
-		}
-		if i == nil {
-			return nil, "", nil
-		}
-
-		return i, aws.StringValue(i.IndexStatus), nil
-	}
-}
-
-func waitDynamoDBGSIDeleted(c *dynamodb.DynamoDB, tn string, in string) error {
-	stateConf := &resource.StateChangeConf{
-		Pending: []string{
+func waitDynamoDBGSIDeleted(ctx context.Context, c *dynamoDBClient, tn string, in string, timeout time.Duration) error {
+	_, err := waitDynamoDBGSI(ctx, c, tn, in, timeout,
+		[]string{
 			dynamodb.IndexStatusDeleting,
 			dynamodb.IndexStatusActive,
 		},
-		Target:  []string{},
-		Timeout: deleteGSITimeout,
-		Refresh: statusDynamoDBGSI(c, tn, in),
-	}
-
-	_, err := stateConf.WaitForState()
+		[]string{""},
+	)
 
 	return err
 }
 
-func waitDynamoDBGSIActive(c *dynamodb.DynamoDB, tn string, in string) error {
-	stateConf := &resource.StateChangeConf{
-		Pending: []string{
+// waitDynamoDBGSIBackfilled waits for an index to be backfilled, unlike waitDynamoDBGSIActive which
+// returns as soon as it is created.
+func waitDynamoDBGSIBackfilled(ctx context.Context, c *dynamoDBClient, tn string, in string, timeout time.Duration) (*dynamodb.GlobalSecondaryIndexDescription, error) {
+	return waitDynamoDBGSI(ctx, c, tn, in, timeout,
+		[]string{
+			dynamodb.IndexStatusCreating,
 			dynamodb.IndexStatusUpdating,
 		},
-		Target: []string{
-			dynamodb.IndexStatusCreating,
+		[]string{
 			dynamodb.IndexStatusActive,
 		},
-		Timeout: createGSITimeout,
-		Refresh: statusDynamoDBGSI(c, tn, in),
-	}
+	)
+}
 
-	_, err := stateConf.WaitForState()
+// waitDynamoDBGSICreated waits for an index being created, until it is backfilled if it is deleted on
+// failure so that a failed backfill is rolled back.
+func waitDynamoDBGSICreated(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string, in string) (*dynamodb.GlobalSecondaryIndexDescription, error) {
+	if d.Get("on_create_failure").(string) == onCreateFailureDelete {
+		return waitDynamoDBGSIBackfilled(ctx, c, tn, in, d.Timeout(schema.TimeoutCreate))
+	}
+	return waitDynamoDBGSIActive(ctx, c, tn, in, d.Timeout(schema.TimeoutCreate))
+}
 
-	return err
+func waitDynamoDBGSIActive(ctx context.Context, c *dynamoDBClient, tn string, in string, timeout time.Duration) (*dynamodb.GlobalSecondaryIndexDescription, error) {
+	return waitDynamoDBGSI(ctx, c, tn, in, timeout,
+		[]string{
+			dynamodb.IndexStatusUpdating,
+		},
+		[]string{
+			dynamodb.IndexStatusCreating,
+			dynamodb.IndexStatusActive,
+		},
+	)
 }
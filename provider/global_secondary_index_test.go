@@ -1,16 +1,19 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -221,7 +224,8 @@ resource "gsi_global_secondary_index" "gsi" {
 	hash_key_type       = "S"
 	range_key           = "r"
 	range_key_type      = "N"
-	autoscaling_enabled = true
+	read_autoscaling_enabled  = true
+	write_autoscaling_enabled = true
 	billing_mode        = "PAY_PER_REQUEST"
 	projection_type     = "KEYS_ONLY"
 }`,
@@ -273,7 +277,8 @@ resource "gsi_global_secondary_index" "gsi" {
 	range_key           = "r"
 	range_key_type      = "N"
 	projection_type     = "KEYS_ONLY"
-	autoscaling_enabled = true
+	read_autoscaling_enabled  = true
+	write_autoscaling_enabled = true
 }`,
 				Check: resource.ComposeTestCheckFunc(
 					waitDynamoGSIActiveCheck(c, "test_table", "basic_index"),
@@ -313,7 +318,8 @@ resource "gsi_global_secondary_index" "gsi" {
 	range_key           = "r"
 	range_key_type      = "N"
 	projection_type     = "KEYS_ONLY"
-	autoscaling_enabled = false
+	read_autoscaling_enabled  = false
+	write_autoscaling_enabled = false
 }`,
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
@@ -333,18 +339,537 @@ resource "gsi_global_secondary_index" "gsi" {
 	range_key           = "r"
 	range_key_type      = "N"
 	projection_type     = "KEYS_ONLY"
-	autoscaling_enabled = true
+	read_autoscaling_enabled  = true
+	write_autoscaling_enabled = true
 }`,
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
 					testAccCheckGSIGlobalSecondaryIndexValues(c, "test_table", "basic_index", "p", "r", "KEYS_ONLY"),
 				),
 			},
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                = "basic_index"
+	table_name          = "test_table"
+	read_capacity       = 5
+	write_capacity      = 5
+	hash_key            = "p"
+	hash_key_type       = "S"
+	range_key           = "r"
+	range_key_type      = "N"
+	projection_type     = "KEYS_ONLY"
+	read_autoscaling_enabled  = false
+	write_autoscaling_enabled = true
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 5, 10),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "read_capacity", "5"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAutoImport(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	input := dynamodb.UpdateTableInput{
+		TableName: aws.String("test_table"),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("p"),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String("r"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName: aws.String("basic_index"),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{
+							AttributeName: aws.String("p"),
+							KeyType:       aws.String(dynamodb.KeyTypeHash),
+						},
+						{
+							AttributeName: aws.String("r"),
+							KeyType:       aws.String(dynamodb.KeyTypeRange),
+						},
+					},
+					Projection: &dynamodb.Projection{
+						NonKeyAttributes: nil,
+						ProjectionType:   aws.String("KEYS_ONLY"),
+					},
+					ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+						ReadCapacityUnits:  aws.Int64(10),
+						WriteCapacityUnits: aws.Int64(10),
+					},
+				},
+			},
+		},
+	}
+
+	_, err = c.UpdateTable(&input)
+	if err != nil {
+		log.Fatal("Failed to update table", err)
+	}
+
+	if _, err = waitDynamoDBGSIActive(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), "test_table", "basic_index", createGSITimeout); err != nil {
+		log.Fatal("Failed to update table", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(true)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                = "basic_index"
+	table_name          = "test_table"
+	read_capacity       = 5
+	write_capacity      = 5
+	hash_key            = "p"
+	hash_key_type       = "S"
+	range_key           = "r"
+	range_key_type      = "S"
+	projection_type     = "KEYS_ONLY"
+	read_autoscaling_enabled  = true
+	write_autoscaling_enabled = true
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+					testAccCheckGSIGlobalSecondaryIndexValues(c, "test_table", "basic_index", "p", "r", "KEYS_ONLY"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccImportTableARN(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	region := aws.StringValue(c.Config.Region)
+	config := `
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	table_name      = "%s"
+	region          = "%s"
+	read_capacity   = 10
+	write_capacity  = 10
+	hash_key        = "p"
+	hash_key_type   = "S"
+	projection_type = "KEYS_ONLY"
+}`
+	arnConfig := fmt.Sprintf(config, fmt.Sprintf("arn:aws:dynamodb:%s:123456789012:table/test_table", region), region)
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(config, "test_table", region),
+				Check:  testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+			},
+			{
+				Config:        arnConfig,
+				ResourceName:  "gsi_global_secondary_index.gsi",
+				ImportState:   true,
+				ImportStateId: fmt.Sprintf("%s:test_table:basic_index", region),
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 || states[0].Attributes["table_name"] != "test_table" {
+						return fmt.Errorf("expected the imported index to hold the table name, got %v", states)
+					}
+					return nil
+				},
+			},
+			{
+				// The state holds the table name, as after an import, the ARN must not replace the index.
+				Config:   arnConfig,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccDeletionProtection(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	config := `
+resource "gsi_global_secondary_index" "gsi" {
+	name                        = "basic_index"
+	table_name                  = "test_table"
+	read_capacity               = 5
+	write_capacity              = 5
+	hash_key                    = "p"
+	hash_key_type               = "S"
+	projection_type             = "KEYS_ONLY"
+	deletion_protection_enabled = %t
+}`
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(config, true),
+				Check: resource.ComposeTestCheckFunc(
+					waitDynamoGSIActiveCheck(c, "test_table", "basic_index"),
+					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+				),
+			},
+			{
+				Config:      fmt.Sprintf(config, true),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile("deletion protection is enabled"),
+			},
+			{
+				Config: fmt.Sprintf(config, false),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccReadOnly(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	config := `
+provider "gsi" {
+	read_only = %t
+}
+
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	table_name      = "test_table"
+	read_capacity   = %d
+	write_capacity  = 5
+	hash_key        = "p"
+	hash_key_type   = "S"
+	projection_type = "KEYS_ONLY"
+}`
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(config, true, 5),
+				ExpectError: regexp.MustCompile("read_only = true"),
+			},
+			{
+				Config: fmt.Sprintf(config, false, 5),
+				Check: resource.ComposeTestCheckFunc(
+					waitDynamoGSIActiveCheck(c, "test_table", "basic_index"),
+					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+				),
+			},
+			{
+				Config:      fmt.Sprintf(config, true, 10),
+				ExpectError: regexp.MustCompile("read_only = true"),
+			},
+			{
+				Config:      fmt.Sprintf(config, true, 10),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile("read_only = true"),
+			},
+			{
+				Config:   fmt.Sprintf(config, false, 5),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccAdoptExistingIfIdentical(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	if err := createGSI(c, "test_table", "basic_index", "p", "S", "r", "S"); err != nil {
+		t.Fatal("Failed to create test index", err)
+	}
+
+	config := `
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	table_name      = "test_table"
+	read_capacity   = 10
+	write_capacity  = 10
+	hash_key        = "p"
+	hash_key_type   = "S"
+	range_key       = "%s"
+	range_key_type  = "S"
+	projection_type = "KEYS_ONLY"
+	adopt_existing  = "if_identical"
+}`
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(config, "s"),
+				ExpectError: regexp.MustCompile(`range_key: existing "r", configured "s"`),
+			},
+			{
+				Config: fmt.Sprintf(config, "r"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
+					testAccCheckGSIGlobalSecondaryIndexValues(c, "test_table", "basic_index", "p", "r", "KEYS_ONLY"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccCapacityRatio(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                 = "basic_index"
+	table_name           = "test_table"
+	read_capacity_ratio  = 0.5
+	write_capacity_ratio = 1
+	hash_key             = "p"
+	hash_key_type        = "S"
+	projection_type      = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					waitDynamoGSIActiveCheck(c, "test_table", "basic_index"),
+					testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 5, 10),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "read_capacity", "5"),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "write_capacity", "10"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccCapacityRatioNewTable(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+	c.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String("test_table")})
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": testProviderWithTable(),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "gsi_test_table" "table" {
+	name     = "test_table"
+	hash_key = "p"
+}
+
+resource "gsi_global_secondary_index" "gsi" {
+	name                 = "basic_index"
+	table_name           = gsi_test_table.table.name
+	read_capacity_ratio  = 0.5
+	write_capacity_ratio = 1
+	hash_key             = "p"
+	hash_key_type        = "S"
+	projection_type      = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 5, 10),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "read_capacity", "5"),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "write_capacity", "10"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSwitchBillingMode(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	table_name      = "test_table"
+	read_capacity   = 5
+	write_capacity  = 5
+	hash_key        = "p"
+	hash_key_type   = "S"
+	projection_type = "KEYS_ONLY"
+}`,
+				Check: testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 5, 5),
+			},
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                      = "basic_index"
+	table_name                = "test_table"
+	billing_mode              = "PAY_PER_REQUEST"
+	switch_table_billing_mode = true
+	hash_key                  = "p"
+	hash_key_type             = "S"
+	projection_type           = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTableBillingMode(c, "test_table", dynamodb.BillingModePayPerRequest),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "billing_mode", dynamodb.BillingModePayPerRequest),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "read_capacity", "0"),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "write_capacity", "0"),
+				),
+			},
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                      = "basic_index"
+	table_name                = "test_table"
+	read_capacity             = 3
+	write_capacity            = 4
+	switch_table_billing_mode = true
+	table_read_capacity       = 10
+	table_write_capacity      = 10
+	hash_key                  = "p"
+	hash_key_type             = "S"
+	projection_type           = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckTableBillingMode(c, "test_table", dynamodb.BillingModeProvisioned),
+					testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 3, 4),
+				),
+			},
+		},
+	})
+}
+
+func TestAccNamePrefix(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name_prefix     = "basic_index_"
+	table_name      = "test_table"
+	read_capacity   = 5
+	write_capacity  = 5
+	hash_key        = "p"
+	hash_key_type   = "S"
+	projection_type = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("gsi_global_secondary_index.gsi", "name", regexp.MustCompile("^basic_index_[0-9a-f]+$")),
+					resource.TestMatchResourceAttr("gsi_global_secondary_index.gsi", "id", regexp.MustCompile("^test_table:basic_index_[0-9a-f]+$")),
+				),
+			},
+			{
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	name_prefix     = "basic_index_"
+	table_name      = "test_table"
+	read_capacity   = 5
+	write_capacity  = 5
+	hash_key        = "p"
+	hash_key_type   = "S"
+	projection_type = "KEYS_ONLY"
+}`,
+				ExpectError: regexp.MustCompile("only one of `name,name_prefix` can be specified"),
+			},
 		},
 	})
 }
 
-func TestAccAutoImport(t *testing.T) {
+func TestAccBackfillWriteCapacity(t *testing.T) {
 	c, err := newTestClient()
 	if err != nil {
 		t.Fatal("Could not create dynamodb client", err)
@@ -355,77 +880,126 @@ func TestAccAutoImport(t *testing.T) {
 		t.Fatal("Failed to create test table", err)
 	}
 
-	input := dynamodb.UpdateTableInput{
-		TableName: aws.String("test_table"),
-		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
+		},
+		Steps: []resource.TestStep{
 			{
-				AttributeName: aws.String("p"),
-				AttributeType: aws.String("S"),
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name                    = "basic_index"
+	table_name              = "test_table"
+	read_capacity           = 5
+	write_capacity          = 5
+	backfill_write_capacity = 20
+	hash_key                = "p"
+	hash_key_type           = "S"
+	projection_type         = "KEYS_ONLY"
+}`,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckGSIGlobalSecondaryIndexCapacity(c, "test_table", "basic_index", 5, 5),
+					resource.TestCheckResourceAttr("gsi_global_secondary_index.gsi", "write_capacity", "5"),
+				),
 			},
-			{
-				AttributeName: aws.String("r"),
-				AttributeType: aws.String("S"),
+		},
+	})
+}
+
+func TestAccPreflightScan(t *testing.T) {
+	c, err := newTestClient()
+	if err != nil {
+		t.Fatal("Could not create dynamodb client", err)
+		return
+	}
+
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	for idx, r := range []*dynamodb.AttributeValue{{N: aws.String("1")}, {S: aws.String("1")}} {
+		_, err := c.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String("test_table"),
+			Item: map[string]*dynamodb.AttributeValue{
+				"p": {S: aws.String(fmt.Sprintf("item_%d", idx))},
+				"r": r,
 			},
+		})
+		if err != nil {
+			t.Fatal("Failed to put test item", err)
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		Providers: map[string]*schema.Provider{
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
 		},
-		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+		Steps: []resource.TestStep{
 			{
-				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
-					IndexName: aws.String("basic_index"),
-					KeySchema: []*dynamodb.KeySchemaElement{
-						{
-							AttributeName: aws.String("p"),
-							KeyType:       aws.String(dynamodb.KeyTypeHash),
-						},
-						{
-							AttributeName: aws.String("r"),
-							KeyType:       aws.String(dynamodb.KeyTypeRange),
-						},
-					},
-					Projection: &dynamodb.Projection{
-						NonKeyAttributes: nil,
-						ProjectionType:   aws.String("KEYS_ONLY"),
-					},
-					ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-						ReadCapacityUnits:  aws.Int64(10),
-						WriteCapacityUnits: aws.Int64(10),
-					},
-				},
+				Config: `
+resource "gsi_global_secondary_index" "gsi" {
+	name            = "basic_index"
+	table_name      = "test_table"
+	read_capacity   = 5
+	write_capacity  = 5
+	hash_key        = "r"
+	hash_key_type   = "N"
+	projection_type = "KEYS_ONLY"
+
+	preflight_scan {
+		segments         = 2
+		fail_on_mismatch = true
+	}
+}`,
+				ExpectError: regexp.MustCompile("pre-flight scan of table test_table found items"),
 			},
 		},
-	}
+	})
+}
 
-	_, err = c.UpdateTable(&input)
+func TestAccVerifyAfterCreate(t *testing.T) {
+	c, err := newTestClient()
 	if err != nil {
-		log.Fatal("Failed to update table", err)
+		t.Fatal("Could not create dynamodb client", err)
+		return
 	}
 
-	if err = waitDynamoDBGSIActive(c, "test_table", "basic_index"); err != nil {
-		log.Fatal("Failed to update table", err)
+	if err := createTable(c, "test_table", map[string]string{"p": "S"}, map[string]string{"p": "HASH"}); err != nil {
+		t.Fatal("Failed to create test table", err)
+	}
+
+	for idx := 0; idx < 10; idx++ {
+		item := map[string]*dynamodb.AttributeValue{"p": {S: aws.String(fmt.Sprintf("item_%d", idx))}}
+		if idx%2 == 0 {
+			item["r"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprint(idx))}
+		}
+		if _, err := c.PutItem(&dynamodb.PutItemInput{TableName: aws.String("test_table"), Item: item}); err != nil {
+			t.Fatal("Failed to put test item", err)
+		}
 	}
 
 	resource.Test(t, resource.TestCase{
 		Providers: map[string]*schema.Provider{
-			"gsi": providerWithConfigure(testProviderConfigure(true)),
+			"gsi": providerWithConfigure(testProviderConfigure(false)),
 		},
 		Steps: []resource.TestStep{
 			{
 				Config: `
 resource "gsi_global_secondary_index" "gsi" {
-	name                = "basic_index"
-	table_name          = "test_table"
-	read_capacity       = 5
-	write_capacity      = 5
-	hash_key            = "p"
-	hash_key_type       = "S"
-	range_key           = "r"
-	range_key_type      = "S"
-	projection_type     = "KEYS_ONLY"
-	autoscaling_enabled = true
+	name            = "basic_index"
+	table_name      = "test_table"
+	read_capacity   = 5
+	write_capacity  = 5
+	hash_key        = "r"
+	hash_key_type   = "N"
+	projection_type = "KEYS_ONLY"
+
+	verify_after_create {
+		segments         = 2
+		fail_on_mismatch = true
+	}
 }`,
-				Check: resource.ComposeTestCheckFunc(
-					testAccCheckGSIGlobalSecondaryIndexExists("gsi", "test_table", "basic_index"),
-					testAccCheckGSIGlobalSecondaryIndexValues(c, "test_table", "basic_index", "p", "r", "KEYS_ONLY"),
-				),
+				Check: testAccCheckGSIGlobalSecondaryIndexValues(c, "test_table", "basic_index", "r", "", "KEYS_ONLY"),
 			},
 		},
 	})
@@ -453,7 +1027,7 @@ func simulateAutoscaling(c *dynamodb.DynamoDB, tn, in string, rc, wc int64) func
 			log.Fatal("Failed to update table", err)
 		}
 
-		if err = waitDynamoDBGSIActive(c, tn, in); err != nil {
+		if _, err = waitDynamoDBGSIActive(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), tn, in, updateGSITimeout); err != nil {
 			log.Fatal("Failed to update table", err)
 		}
 	}
@@ -461,7 +1035,7 @@ func simulateAutoscaling(c *dynamodb.DynamoDB, tn, in string, rc, wc int64) func
 
 func testAccCheckGSIGlobalSecondaryIndexValues(c *dynamodb.DynamoDB, tn, in string, hashKey, rangeKey string, projection string) resource.TestCheckFunc {
 	return func(_ *terraform.State) error {
-		_, gsi, err := describeGSI(c, tn, in)
+		_, gsi, err := describeGSI(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), tn, in)
 		if err != nil {
 			return err
 		}
@@ -496,6 +1070,40 @@ func testAccCheckGSIGlobalSecondaryIndexValues(c *dynamodb.DynamoDB, tn, in stri
 	}
 }
 
+func testAccCheckGSIGlobalSecondaryIndexCapacity(c *dynamodb.DynamoDB, tn, in string, rc, wc int64) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		_, gsi, err := describeGSI(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), tn, in)
+		if err != nil {
+			return err
+		}
+
+		if gsi == nil {
+			return fmt.Errorf("GSI %s not found on table %s", in, tn)
+		}
+
+		if r, w := aws.Int64Value(gsi.ProvisionedThroughput.ReadCapacityUnits), aws.Int64Value(gsi.ProvisionedThroughput.WriteCapacityUnits); r != rc || w != wc {
+			return fmt.Errorf("Invalid capacity %d/%d, expected %d/%d", r, w, rc, wc)
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckTableBillingMode(c *dynamodb.DynamoDB, tn string, mode string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		out, err := c.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(tn)})
+		if err != nil {
+			return err
+		}
+
+		if m := tableBillingMode(out.Table); m != mode {
+			return fmt.Errorf("Invalid billing mode %s, expected %s", m, mode)
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckGSIGlobalSecondaryIndexExists(rn, tn, in string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		id := tn + ":" + in
@@ -517,6 +1125,20 @@ func testAccCheckGSIGlobalSecondaryIndexExists(rn, tn, in string) resource.TestC
 	}
 }
 
+func statusDynamoDBGSI(ctx context.Context, c *dynamoDBClient, tn string, in string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		_, i, err := describeGSI(ctx, c, tn, in)
+		if err != nil {
+			return nil, "", err
+		}
+		if i == nil {
+			return nil, "", nil
+		}
+
+		return i, aws.StringValue(i.IndexStatus), nil
+	}
+}
+
 // Waits for the index to be ready before running validation.
 // Test cleanup could happen before the index is fully created
 func waitDynamoGSIActiveCheck(c *dynamodb.DynamoDB, tn, in string) resource.TestCheckFunc {
@@ -530,7 +1152,7 @@ func waitDynamoGSIActiveCheck(c *dynamodb.DynamoDB, tn, in string) resource.Test
 				dynamodb.IndexStatusActive,
 			},
 			Timeout: createGSITimeout,
-			Refresh: statusDynamoDBGSI(c, tn, in),
+			Refresh: statusDynamoDBGSI(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), tn, in),
 		}
 
 		_, err := stateConf.WaitForState()
@@ -538,3 +1160,358 @@ func waitDynamoGSIActiveCheck(c *dynamodb.DynamoDB, tn, in string) resource.Test
 		return err
 	}
 }
+
+func TestIdToNames(t *testing.T) {
+	cases := []struct {
+		id     string
+		region string
+		tn     string
+		in     string
+		err    bool
+	}{
+		{id: "test_table:test_index", tn: "test_table", in: "test_index"},
+		{id: "us-west-2:test_table:test_index", region: "us-west-2", tn: "test_table", in: "test_index"},
+		{id: "test_table", err: true},
+		{id: "test_table:", err: true},
+		{id: "a:b:c:d", err: true},
+	}
+
+	for _, tc := range cases {
+		region, tn, in, err := idToNames(tc.id)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.id)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tc.id, err)
+		} else if region != tc.region || tn != tc.tn || in != tc.in {
+			t.Errorf("%s: got (%s, %s, %s)", tc.id, region, tn, in)
+		}
+
+		if id := namesToID(region, tn, in); id != tc.id {
+			t.Errorf("%s: round trip returned %s", tc.id, id)
+		}
+	}
+}
+
+func TestResolveTable(t *testing.T) {
+	cases := []struct {
+		raw    map[string]interface{}
+		region string
+		tn     string
+		err    bool
+	}{
+		{raw: map[string]interface{}{"table_name": "test_table"}, tn: "test_table"},
+		{raw: map[string]interface{}{"table_name": "test_table", "region": "eu-west-1"}, region: "eu-west-1", tn: "test_table"},
+		{raw: map[string]interface{}{"table_name": "arn:aws:dynamodb:us-west-2:123456789012:table/test_table"}, region: "us-west-2", tn: "test_table"},
+		{raw: map[string]interface{}{"table_name": "arn:aws:dynamodb:us-west-2:123456789012:table/test_table", "region": "us-west-2"}, region: "us-west-2", tn: "test_table"},
+		{raw: map[string]interface{}{"table_name": "arn:aws:dynamodb:us-west-2:123456789012:table/test_table", "region": "eu-west-1"}, err: true},
+		{raw: map[string]interface{}{"table_name": "arn:aws:s3:::test_bucket"}, err: true},
+	}
+
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, tc.raw)
+		region, tn, err := resolveTable(d)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v: expected an error", tc.raw)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: unexpected error %s", tc.raw, err)
+		} else if region != tc.region || tn != tc.tn {
+			t.Errorf("%v: got (%s, %s)", tc.raw, region, tn)
+		}
+	}
+}
+
+func TestSameTableName(t *testing.T) {
+	tableARN := "arn:aws:dynamodb:us-west-2:123456789012:table/test_table"
+	cases := []struct {
+		old    string
+		new    string
+		region string
+		same   bool
+	}{
+		{old: "test_table", new: tableARN, region: "us-west-2", same: true},
+		{old: tableARN, new: "test_table", region: "us-west-2", same: true},
+		{old: "test_table", new: tableARN, same: true},
+		{old: "test_table", new: tableARN, region: "eu-west-1"},
+		{old: "other_table", new: tableARN, region: "us-west-2"},
+		{old: "test_table", new: "other_table"},
+		{old: tableARN, new: "arn:aws:dynamodb:eu-west-1:123456789012:table/test_table"},
+		{old: "test_bucket", new: "arn:aws:s3:::test_bucket"},
+	}
+
+	for _, tc := range cases {
+		if same := sameTableName(tc.old, tc.new, tc.region); same != tc.same {
+			t.Errorf("(%s, %s, %s): expected %t, got %t", tc.old, tc.new, tc.region, tc.same, same)
+		}
+	}
+}
+
+func TestDiffExistingGSI(t *testing.T) {
+	table := &dynamodb.TableDescription{
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("p"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("r"), AttributeType: aws.String("N")},
+		},
+	}
+	index := &dynamodb.GlobalSecondaryIndexDescription{
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("p"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("r"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType:   aws.String(dynamodb.ProjectionTypeInclude),
+			NonKeyAttributes: aws.StringSlice([]string{"a", "b"}),
+		},
+	}
+
+	raw := map[string]interface{}{
+		"hash_key":           "p",
+		"hash_key_type":      "S",
+		"range_key":          "r",
+		"range_key_type":     "N",
+		"projection_type":    dynamodb.ProjectionTypeInclude,
+		"non_key_attributes": []interface{}{"b", "a"},
+	}
+	if diffs := diffExistingGSI(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), table, index); len(diffs) != 0 {
+		t.Errorf("expected no differences, got %v", diffs)
+	}
+
+	raw["range_key_type"] = "S"
+	raw["non_key_attributes"] = []interface{}{"a"}
+	if diffs := diffExistingGSI(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), table, index); len(diffs) != 2 {
+		t.Errorf("expected 2 differences, got %v", diffs)
+	}
+}
+
+func TestUpgradeDynamoDBGSIStateV0(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		state, err := upgradeDynamoDBGSIStateV0(context.Background(), map[string]interface{}{
+			"name":                "basic_index",
+			"autoscaling_enabled": enabled,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := state["autoscaling_enabled"]; ok {
+			t.Errorf("expected autoscaling_enabled to be removed, got %v", state)
+		}
+		if state["read_autoscaling_enabled"] != enabled || state["write_autoscaling_enabled"] != enabled {
+			t.Errorf("expected read and write autoscaling to be %t, got %v", enabled, state)
+		}
+		if state["name"] != "basic_index" {
+			t.Errorf("expected the other attributes to be kept, got %v", state)
+		}
+	}
+}
+
+func TestProvisionedThroughputUpdate(t *testing.T) {
+	live := &dynamodb.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(10),
+		WriteCapacityUnits: aws.Int64(20),
+	}
+
+	cases := []struct {
+		readAutoscaling  bool
+		writeAutoscaling bool
+		read             int64
+		write            int64
+		changed          bool
+	}{
+		// Both dimensions autoscaled, the live throughput is kept.
+		{true, true, 10, 20, false},
+		// Read autoscaling turned off, read goes back to the configuration and write is kept.
+		{false, true, 5, 20, true},
+		// Both turned off, applied together.
+		{false, false, 5, 5, true},
+	}
+	for _, tc := range cases {
+		d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, map[string]interface{}{
+			"read_capacity":             5,
+			"write_capacity":            5,
+			"read_autoscaling_enabled":  tc.readAutoscaling,
+			"write_autoscaling_enabled": tc.writeAutoscaling,
+		})
+
+		throughput := provisionedThroughputUpdate(d, nil, live)
+		if !tc.changed {
+			if throughput != nil {
+				t.Errorf("expected no update, got %v", throughput)
+			}
+			continue
+		}
+
+		if throughput == nil {
+			t.Fatalf("expected an update to %d/%d, got none", tc.read, tc.write)
+		}
+		if r, w := aws.Int64Value(throughput.ReadCapacityUnits), aws.Int64Value(throughput.WriteCapacityUnits); r != tc.read || w != tc.write {
+			t.Errorf("expected an update to %d/%d, got %d/%d", tc.read, tc.write, r, w)
+		}
+	}
+
+	d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, map[string]interface{}{
+		"read_capacity":  10,
+		"write_capacity": 20,
+	})
+	if throughput := provisionedThroughputUpdate(d, nil, live); throughput != nil {
+		t.Errorf("expected no update when the live throughput matches, got %v", throughput)
+	}
+}
+
+func TestProvisionedThroughputUpdateDetected(t *testing.T) {
+	live := &dynamodb.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(10),
+		WriteCapacityUnits: aws.Int64(20),
+	}
+	targets := map[string]*applicationautoscaling.ScalableTarget{
+		applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits: {},
+	}
+
+	d := schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, map[string]interface{}{
+		"read_capacity":  5,
+		"write_capacity": 5,
+	})
+	throughput := provisionedThroughputUpdate(d, targets, live)
+	if throughput == nil {
+		t.Fatal("expected an update of the read capacity, got none")
+	}
+	if r, w := aws.Int64Value(throughput.ReadCapacityUnits), aws.Int64Value(throughput.WriteCapacityUnits); r != 5 || w != 20 {
+		t.Errorf("expected an update to 5/20, got %d/%d", r, w)
+	}
+}
+
+func TestBillingModeUpdate(t *testing.T) {
+	onDemand := &dynamodb.TableDescription{
+		BillingModeSummary: &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModePayPerRequest)},
+	}
+
+	raw := map[string]interface{}{
+		"billing_mode":   dynamodb.BillingModeProvisioned,
+		"read_capacity":  5,
+		"write_capacity": 5,
+	}
+	if update, err := billingModeUpdate(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), &dynamodb.TableDescription{}, "test_table"); err != nil || update.billingMode != "" {
+		t.Errorf("expected no switch of a table without billing mode summary, got %v, %v", update, err)
+	}
+
+	if _, err := billingModeUpdate(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), onDemand, "test_table"); err == nil {
+		t.Error("expected an error when the table billing mode differs without switch_table_billing_mode")
+	}
+
+	raw["switch_table_billing_mode"] = true
+	if _, err := billingModeUpdate(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), onDemand, "test_table"); err == nil {
+		t.Error("expected an error when switching to PROVISIONED without the table capacity")
+	}
+
+	raw["table_read_capacity"] = 10
+	raw["table_write_capacity"] = 20
+	update, err := billingModeUpdate(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, raw), onDemand, "test_table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.billingMode != dynamodb.BillingModeProvisioned || aws.Int64Value(update.throughput.ReadCapacityUnits) != 10 || aws.Int64Value(update.throughput.WriteCapacityUnits) != 20 {
+		t.Errorf("expected the table to switch to PROVISIONED with 10/20, got %v", update)
+	}
+}
+
+func TestSameTable(t *testing.T) {
+	created := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	table := &dynamodb.TableDescription{
+		TableId:          aws.String("a1b2c3"),
+		CreationDateTime: aws.Time(created),
+	}
+
+	cases := []struct {
+		name string
+		raw  map[string]interface{}
+		same bool
+	}{
+		{"identity not recorded", map[string]interface{}{}, true},
+		{"same table", map[string]interface{}{"table_id": "a1b2c3", "table_creation_time": "2023-05-10T12:00:00Z"}, true},
+		{"re-created table", map[string]interface{}{"table_id": "d4e5f6", "table_creation_time": "2023-05-10T12:00:00Z"}, false},
+		{"re-created table without identifier", map[string]interface{}{"table_creation_time": "2023-05-09T12:00:00Z"}, false},
+	}
+	for _, tc := range cases {
+		if same := sameTable(schema.TestResourceDataRaw(t, dynamoDBGSIResource().Schema, tc.raw), table); same != tc.same {
+			t.Errorf("%s: expected %t, got %t", tc.name, tc.same, same)
+		}
+	}
+}
+
+func TestResumeCreate(t *testing.T) {
+	// An interrupted create is resumed without adopt_existing, unless the provider is read-only.
+	f := &fakeDynamoDB{table: testTableDescription(), statuses: map[string][]string{"basic_index": {dynamodb.IndexStatusCreating}}}
+	p := newFakeDynamoDBProvider(t, f)
+	p.readOnly = true
+	d := testCreateResourceData(t, time.Minute, nil)
+	if diags := dynamoDBGSICreate(context.Background(), d, p); !diags.HasError() || !strings.Contains(diags[0].Summary, "read_only") {
+		t.Errorf("expected the read_only error, got %v", diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected no ID with read_only, got %s", d.Id())
+	}
+
+	// Only a resumed create warns about the backfill, an adopted index is not waited for.
+	p.readOnly = false
+	d = testCreateResourceData(t, time.Minute, nil)
+	diags := dynamoDBGSICreate(context.Background(), d, p)
+	if diags.HasError() || len(diags) != 1 || !strings.Contains(diags[0].Summary, "still backfilling") {
+		t.Fatalf("expected the create to be resumed, got %v", diags)
+	}
+	if d.Id() != "test_table:basic_index" {
+		t.Errorf("expected the index to be recorded, got %q", d.Id())
+	}
+	// The fake server may still be serving the last poll of the create.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.updates) != 0 {
+		t.Errorf("expected no table update, got %v", f.updates)
+	}
+}
+
+func TestCreateFailure(t *testing.T) {
+	table := testTableDescription()
+	table.GlobalSecondaryIndexes = nil
+	f := &fakeDynamoDB{table: table, statuses: map[string][]string{}, createStatuses: []string{dynamodb.IndexStatusCreating}}
+	p := newFakeDynamoDBProvider(t, f)
+
+	// The backfill never completes, the index is kept once created unless it is deleted on failure.
+	d := testCreateResourceData(t, 100*time.Millisecond, nil)
+	if diags := dynamoDBGSICreate(context.Background(), d, p); diags.HasError() {
+		t.Fatalf("expected the index to be kept, got %v", diags)
+	}
+	// The fake server may still be serving the last poll of the create.
+	f.mu.Lock()
+	if d.Id() != "test_table:basic_index" || len(f.table.GlobalSecondaryIndexes) != 1 {
+		t.Errorf("expected the index to exist, got %q and %v", d.Id(), f.table.GlobalSecondaryIndexes)
+	}
+	f.table.GlobalSecondaryIndexes = nil
+	f.updates = nil
+	f.mu.Unlock()
+
+	// The SDK bounds the create context by the create timeout, the deletion must not use it.
+	d = testCreateResourceData(t, 100*time.Millisecond, map[string]interface{}{"on_create_failure": onCreateFailureDelete})
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout(schema.TimeoutCreate))
+	defer cancel()
+	diags := dynamoDBGSICreate(ctx, d, p)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "timeout") {
+		t.Errorf("expected the backfill timeout, got %v", diags)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d.Id() != "" || len(f.table.GlobalSecondaryIndexes) != 0 {
+		t.Errorf("expected the index to be deleted, got %q and %v", d.Id(), f.table.GlobalSecondaryIndexes)
+	}
+	if len(f.updates) != 2 || len(f.updates[1].GlobalSecondaryIndexUpdates) != 1 || f.updates[1].GlobalSecondaryIndexUpdates[0].Delete == nil {
+		t.Errorf("expected the index to be created then deleted, got %v", f.updates)
+	}
+}
@@ -0,0 +1,258 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	defaultPollMinInterval = 2 * time.Second
+	defaultPollMaxInterval = 30 * time.Second
+
+	// Number of polls an index may be missing for before a wait for it to become active fails, the
+	// table description is only eventually consistent after an update.
+	notFoundChecks = 20
+)
+
+// backoff returns exponentially increasing intervals between min and max with a random jitter.
+type backoff struct {
+	min  time.Duration
+	max  time.Duration
+	next time.Duration
+}
+
+func newBackoff(min time.Duration, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, next: min}
+}
+
+func (b *backoff) reset() {
+	b.next = b.min
+}
+
+func (b *backoff) duration() time.Duration {
+	d := b.next
+	if b.next *= 2; b.next > b.max {
+		b.next = b.max
+	}
+
+	// Spread the polls between d/2 and d so that waits started together don't stay in sync.
+	if half := int64(d / 2); half > 0 {
+		return time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
+
+type tablePoll struct {
+	started bool
+	// done is closed once the poll completes.
+	done  chan struct{}
+	table *dynamodb.TableDescription
+	err   error
+}
+
+type tablePoller struct {
+	waiters int
+	next    *tablePoll
+	// wake resets the backoff and polls right away when a waiter joins.
+	wake chan struct{}
+	// stop is closed along with the last subscription to end the loop.
+	stop chan struct{}
+}
+
+// tablePollers runs a single polling loop per table, shared by all the waits on its indexes.
+type tablePollers struct {
+	min time.Duration
+	max time.Duration
+
+	mu      sync.Mutex
+	pollers map[string]*tablePoller
+}
+
+func newTablePollers(min time.Duration, max time.Duration) *tablePollers {
+	return &tablePollers{
+		min:     min,
+		max:     max,
+		pollers: map[string]*tablePoller{},
+	}
+}
+
+type tableSubscription struct {
+	pollers *tablePollers
+	key     string
+	poller  *tablePoller
+	// seen is the last poll returned to the subscriber, or the poll in progress when it subscribed
+	// since it may predate the change the subscriber waits for.
+	seen *tablePoll
+}
+
+// subscribe registers a waiter on the polling loop of a table, starting the loop if needed. The
+// subscription must be closed once the waiter is done.
+func (tp *tablePollers) subscribe(key string, describe func() (*dynamodb.TableDescription, error)) *tableSubscription {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	poller, ok := tp.pollers[key]
+	if !ok {
+		poller = &tablePoller{
+			next: &tablePoll{done: make(chan struct{})},
+			wake: make(chan struct{}, 1),
+			stop: make(chan struct{}),
+		}
+		tp.pollers[key] = poller
+		go tp.run(poller, describe)
+	} else {
+		select {
+		case poller.wake <- struct{}{}:
+		default:
+		}
+	}
+	poller.waiters++
+
+	sub := &tableSubscription{pollers: tp, key: key, poller: poller}
+	if poller.next.started {
+		sub.seen = poller.next
+	}
+	return sub
+}
+
+func (s *tableSubscription) close() {
+	s.pollers.mu.Lock()
+	defer s.pollers.mu.Unlock()
+
+	if s.poller.waiters--; s.poller.waiters == 0 {
+		// A later subscription starts a new loop, the stopped one may still finish its poll.
+		delete(s.pollers.pollers, s.key)
+		close(s.poller.stop)
+	}
+}
+
+// next waits for the next poll of the table.
+func (s *tableSubscription) next(ctx context.Context) (*dynamodb.TableDescription, error) {
+	for {
+		s.pollers.mu.Lock()
+		poll := s.poller.next
+		s.pollers.mu.Unlock()
+
+		select {
+		case <-poll.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if poll != s.seen {
+			s.seen = poll
+			return poll.table, poll.err
+		}
+	}
+}
+
+func (tp *tablePollers) run(poller *tablePoller, describe func() (*dynamodb.TableDescription, error)) {
+	b := newBackoff(tp.min, tp.max)
+	for {
+		tp.mu.Lock()
+		if poller.waiters == 0 {
+			tp.mu.Unlock()
+			return
+		}
+		poll := poller.next
+		poll.started = true
+		tp.mu.Unlock()
+
+		poll.table, poll.err = describe()
+
+		tp.mu.Lock()
+		poller.next = &tablePoll{done: make(chan struct{})}
+		close(poll.done)
+		tp.mu.Unlock()
+
+		select {
+		case <-time.After(b.duration()):
+		case <-poller.wake:
+			b.reset()
+		case <-poller.stop:
+			return
+		}
+	}
+}
+
+// waitDynamoDBGSI waits for an index to reach one of the target statuses, an index which does not
+// exist has an empty status.
+func waitDynamoDBGSI(ctx context.Context, c *dynamoDBClient, tn string, in string, timeout time.Duration, pending []string, target []string) (*dynamodb.GlobalSecondaryIndexDescription, error) {
+	pollers := c.pollers
+	if pollers == nil {
+		pollers = newTablePollers(defaultPollMinInterval, defaultPollMaxInterval)
+	}
+
+	// The polls bypass the table cache since they need fresh descriptions.
+	sub := pollers.subscribe(c.region+":"+tn, func() (*dynamodb.TableDescription, error) {
+		t, err := c.DescribeTableWithContext(context.Background(), &dynamodb.DescribeTableInput{
+			TableName: aws.String(tn),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("error reading Dynamodb Table (%s): %w", tn, err)
+		}
+		return t.Table, nil
+	})
+	defer sub.close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	status := ""
+	notFound := 0
+	for {
+		t, err := sub.next(ctx)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("timeout while waiting for state to become '%s' (last state: '%s', timeout: %s)", strings.Join(target, ", "), status, timeout)
+			}
+			return nil, err
+		}
+
+		var i *dynamodb.GlobalSecondaryIndexDescription
+		if t != nil {
+			for _, gsi := range t.GlobalSecondaryIndexes {
+				if aws.StringValue(gsi.IndexName) == in {
+					i = gsi
+				}
+			}
+		}
+
+		status = ""
+		if i != nil {
+			status = aws.StringValue(i.IndexStatus)
+		}
+
+		for _, s := range target {
+			if s == status {
+				return i, nil
+			}
+		}
+
+		if i == nil {
+			if notFound++; notFound > notFoundChecks {
+				return nil, fmt.Errorf("couldn't find DynamoDB GSI (%s) on table %s", in, tn)
+			}
+			continue
+		}
+
+		found := false
+		for _, s := range pending {
+			found = found || s == status
+		}
+		if !found {
+			return i, fmt.Errorf("unexpected state '%s', wanted target '%s'", status, strings.Join(target, ", "))
+		}
+	}
+}
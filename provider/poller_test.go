@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 8*time.Second)
+	for _, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		if d := b.duration(); d < max/2 || d > max {
+			t.Errorf("expected a duration between %s and %s, got %s", max/2, max, d)
+		}
+	}
+
+	b.reset()
+	if d := b.duration(); d > time.Second {
+		t.Errorf("expected the backoff to be reset, got %s", d)
+	}
+}
+
+func TestTablePollersShared(t *testing.T) {
+	tp := newTablePollers(10*time.Millisecond, 20*time.Millisecond)
+
+	var calls int32
+	describe := func() (*dynamodb.TableDescription, error) {
+		n := atomic.AddInt32(&calls, 1)
+		return &dynamodb.TableDescription{ItemCount: aws.Int64(int64(n))}, nil
+	}
+
+	var wg sync.WaitGroup
+	subs := []*tableSubscription{}
+	for i := 0; i < 5; i++ {
+		subs = append(subs, tp.subscribe("test_table", describe))
+	}
+
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *tableSubscription) {
+			defer wg.Done()
+			defer sub.close()
+			for i := 0; i < 3; i++ {
+				if _, err := sub.next(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}(sub)
+	}
+	wg.Wait()
+
+	// Each subscriber saw 3 polls which should come from the same loop, subscribers joining while the
+	// first poll is in progress and waking up the loop can add a couple of polls.
+	if n := atomic.LoadInt32(&calls); n > 5 {
+		t.Errorf("expected the polls to be shared, got %d calls", n)
+	}
+}
+
+func TestTablePollersSkipsPollInProgress(t *testing.T) {
+	tp := newTablePollers(time.Millisecond, time.Millisecond)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+	describe := func() (*dynamodb.TableDescription, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		return &dynamodb.TableDescription{ItemCount: aws.Int64(int64(n))}, nil
+	}
+
+	first := tp.subscribe("test_table", describe)
+	defer first.close()
+	<-started
+
+	// The second subscriber joins while the first poll is in progress and must not get its result.
+	second := tp.subscribe("test_table", describe)
+	defer second.close()
+	close(release)
+
+	table, err := second.next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.Int64Value(table.ItemCount) < 2 {
+		t.Errorf("expected a poll started after subscribing, got poll %d", aws.Int64Value(table.ItemCount))
+	}
+}
+
+func TestTablePollersCancel(t *testing.T) {
+	tp := newTablePollers(time.Hour, time.Hour)
+	sub := tp.subscribe("test_table", func() (*dynamodb.TableDescription, error) {
+		return &dynamodb.TableDescription{}, nil
+	})
+	defer sub.close()
+
+	if _, err := sub.next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sub.next(ctx); err != context.Canceled {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
+
+func TestTablePollersStop(t *testing.T) {
+	tp := newTablePollers(10*time.Millisecond, 10*time.Millisecond)
+
+	var calls int32
+	sub := tp.subscribe("test_table", func() (*dynamodb.TableDescription, error) {
+		atomic.AddInt32(&calls, 1)
+		return &dynamodb.TableDescription{}, nil
+	})
+	if _, err := sub.next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sub.close()
+
+	tp.mu.Lock()
+	n := len(tp.pollers)
+	tp.mu.Unlock()
+	if n != 0 {
+		t.Errorf("expected the poller to be removed, got %d pollers", n)
+	}
+
+	// The loop stops with the last subscription instead of polling once more.
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected a single poll, got %d", n)
+	}
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// preflightResult counts the items scanned before creating an index by the state of their index keys.
+type preflightResult struct {
+	scanned int64
+	// Items without one of the key attributes are not indexed, which is expected of sparse indexes.
+	missing int64
+	// Items with a key attribute of another type make the backfill fail.
+	mismatched int64
+}
+
+func (r *preflightResult) add(other *preflightResult) {
+	r.scanned += other.scanned
+	r.missing += other.missing
+	r.mismatched += other.mismatched
+}
+
+// checkKeyAttributes classifies an item by the presence and type of the index key attributes.
+func (r *preflightResult) checkKeyAttributes(item map[string]*dynamodb.AttributeValue, keys map[string]string) {
+	r.scanned++
+
+	missing := false
+	for name, keyType := range keys {
+		av, ok := item[name]
+		if !ok || av == nil || aws.BoolValue(av.NULL) {
+			missing = true
+			continue
+		}
+
+		var matches bool
+		switch keyType {
+		case dynamodb.ScalarAttributeTypeS:
+			matches = av.S != nil
+		case dynamodb.ScalarAttributeTypeN:
+			matches = av.N != nil
+		case dynamodb.ScalarAttributeTypeB:
+			matches = av.B != nil
+		}
+		if !matches {
+			r.mismatched++
+			return
+		}
+	}
+
+	if missing {
+		r.missing++
+	}
+}
+
+// preflightScan scans the table in parallel segments and checks the index key attributes of its items.
+// At most sampleSize items are scanned, or the whole table if it is 0.
+func preflightScan(ctx context.Context, c *dynamoDBClient, tn string, keys map[string]string, segments int, sampleSize int64) (*preflightResult, error) {
+	// Only the key attributes are read.
+	placeholders, names := keyAttributeNames(keys)
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(tn),
+		ProjectionExpression:     aws.String(strings.Join(placeholders, ", ")),
+		ExpressionAttributeNames: names,
+	}
+
+	var perSegment int64
+	if sampleSize > 0 {
+		perSegment = (sampleSize + int64(segments) - 1) / int64(segments)
+		input.Limit = aws.Int64(perSegment)
+	}
+
+	results := make([]*preflightResult, segments)
+	for segment := range results {
+		results[segment] = &preflightResult{}
+	}
+	err := parallelScan(ctx, c, input, segments, func(segment int, input *dynamodb.ScanInput, out *dynamodb.ScanOutput) bool {
+		result := results[segment]
+		for _, item := range out.Items {
+			result.checkKeyAttributes(item, keys)
+		}
+
+		if perSegment == 0 {
+			return true
+		}
+		input.Limit = aws.Int64(perSegment - result.scanned)
+		return result.scanned < perSegment
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := &preflightResult{}
+	for _, result := range results {
+		total.add(result)
+	}
+	return total, nil
+}
+
+// preflightDiags runs the pre-flight scan configured on an index and reports its result, as an error
+// if items have a key attribute of the wrong type and fail_on_mismatch is set.
+func preflightDiags(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string, in string, keys map[string]string) diag.Diagnostics {
+	v, ok := d.GetOk("preflight_scan")
+	if !ok {
+		return nil
+	}
+
+	// An empty block has no attributes set.
+	config, _ := v.([]interface{})[0].(map[string]interface{})
+	segments := defaultScanSegments
+	var sampleSize int64
+	failOnMismatch := false
+	if config != nil {
+		segments = config["segments"].(int)
+		sampleSize = int64(config["sample_size"].(int))
+		failOnMismatch = config["fail_on_mismatch"].(bool)
+	}
+
+	result, err := preflightScan(ctx, c, tn, keys, segments, sampleSize)
+	if err != nil {
+		return errorDiags(err)
+	}
+	if result.missing == 0 && result.mismatched == 0 {
+		return nil
+	}
+
+	severity := diag.Warning
+	if failOnMismatch && result.mismatched > 0 {
+		severity = diag.Error
+	}
+	return diag.Diagnostics{{
+		Severity: severity,
+		Summary:  fmt.Sprintf("pre-flight scan of table %s found items which DynamoDB GSI (%s) cannot index", tn, in),
+		Detail:   fmt.Sprintf("%d items scanned: %d with a key attribute of the wrong type, which makes the backfill fail, and %d without all the key attributes, which are not indexed.", result.scanned, result.mismatched, result.missing),
+	}}
+}
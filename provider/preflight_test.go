@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCheckKeyAttributes(t *testing.T) {
+	keys := map[string]string{"p": dynamodb.ScalarAttributeTypeS, "r": dynamodb.ScalarAttributeTypeN}
+	items := []map[string]*dynamodb.AttributeValue{
+		{"p": {S: aws.String("a")}, "r": {N: aws.String("1")}},
+		{"p": {S: aws.String("a")}},
+		{"p": {NULL: aws.Bool(true)}, "r": {N: aws.String("1")}},
+		{"p": {N: aws.String("1")}, "r": {N: aws.String("1")}},
+		{"p": {S: aws.String("a")}, "r": {S: aws.String("1")}},
+	}
+
+	result := &preflightResult{}
+	for _, item := range items {
+		result.checkKeyAttributes(item, keys)
+	}
+
+	if result.scanned != 5 || result.missing != 2 || result.mismatched != 2 {
+		t.Errorf("expected 5 items scanned, 2 missing and 2 mismatched, got %+v", result)
+	}
+}
@@ -1,23 +1,51 @@
 package provider
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 type GSIProvider struct {
-	c          *dynamodb.DynamoDB
-	autoImport bool
+	c                  *dynamoDBClient
+	autoImport         bool
+	deletionProtection bool
+	readOnly           bool
+
+	// Clients for regions other than the provider one are created lazily from the session.
+	sess      *session.Session
+	region    string
+	clientsMu sync.Mutex
+	clients   map[string]*dynamoDBClient
+	tables    *tableCache
+	pollers   *tablePollers
+	batcher   *updateBatcher
+
+	// Application Auto Scaling targets are only detected on refresh if detectAutoscaling is set.
+	detectAutoscaling   bool
+	autoscalingEndpoint string
+	dynamodbEndpoint    string
+
+	// Identity of the caller, only resolved when credentials are validated.
+	accountID string
+	callerARN string
 }
 
-func providerWithConfigure(cfgFn schema.ConfigureFunc) *schema.Provider {
+func providerWithConfigure(cfgFn schema.ConfigureContextFunc) *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"access_key": {
@@ -53,6 +81,21 @@ func providerWithConfigure(cfgFn schema.ConfigureFunc) *schema.Provider {
 				Optional:    true,
 				Default:     false,
 				Description: "Automatically import on create, not recommended unless transitioning away from GSI created with the AWS resource",
+				Deprecated:  "Use adopt_existing on the gsi_global_secondary_index resource instead",
+			},
+
+			"deletion_protection_enabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Default deletion protection for the indexes which do not set deletion_protection_enabled.",
+			},
+
+			"read_only": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Prevent the provider from creating, updating or deleting indexes, reads and imports still work.",
 			},
 
 			"region": {
@@ -72,6 +115,43 @@ func providerWithConfigure(cfgFn schema.ConfigureFunc) *schema.Provider {
 				Description: "AWS dynamodb endpoint",
 			},
 
+			"sts_endpoint": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("AWS_STS_ENDPOINT", nil),
+				Description: "AWS sts endpoint, used to validate credentials",
+			},
+
+			"application_autoscaling_endpoint": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("AWS_APPLICATION_AUTOSCALING_ENDPOINT", nil),
+				Description: "AWS application autoscaling endpoint, used to detect the autoscaling of the indexes",
+			},
+
+			"detect_autoscaling": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Detect the Application Auto Scaling targets of the indexes on refresh and suppress the capacity diffs of the autoscaled dimensions. Requires application-autoscaling:DescribeScalableTargets, and application_autoscaling_endpoint along with dynamodb_endpoint.",
+			},
+
+			"poll_min_interval": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      defaultPollMinInterval.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Minimum interval between polls of a table while waiting for its indexes, the interval grows exponentially up to poll_max_interval.",
+			},
+
+			"poll_max_interval": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      defaultPollMaxInterval.String(),
+				ValidateFunc: validateDuration,
+				Description:  "Maximum interval between polls of a table while waiting for its indexes.",
+			},
+
 			"assume_role": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -91,13 +171,13 @@ func providerWithConfigure(cfgFn schema.ConfigureFunc) *schema.Provider {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     true,
-				Description: "Validate AWS credentials passed to the provider. Consider setting false if using an IAM role or EC2 instance profile.",
+				Description: "Validate AWS credentials passed to the provider with a call to STS GetCallerIdentity, skipped if dynamodb_endpoint is set without sts_endpoint. Consider setting false if using an IAM role or EC2 instance profile.",
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"gsi_global_secondary_index": dynamoDBGSIResource(),
 		},
-		ConfigureFunc: cfgFn,
+		ConfigureContextFunc: cfgFn,
 	}
 }
 
@@ -106,6 +186,15 @@ func Provider() *schema.Provider {
 }
 
 func newClient(region string, accessKey string, secretKey string, token string, profile string, endpoint string, role_arn string, validate bool) (*dynamodb.DynamoDB, error) {
+	sess, err := newSession(region, accessKey, secretKey, token, profile, endpoint, "", role_arn, validate)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.New(sess), nil
+}
+
+func newSession(region string, accessKey string, secretKey string, token string, profile string, endpoint string, stsEndpoint string, role_arn string, validate bool) (*session.Session, error) {
 	options := session.Options{}
 	options.Config = *aws.NewConfig().WithRegion(region)
 	if accessKey != "" && secretKey != "" {
@@ -117,14 +206,20 @@ func newClient(region string, accessKey string, secretKey string, token string,
 		return nil, errors.New("no credentials for AWS")
 	}
 
-	if endpoint != "" {
+	if endpoint != "" || stsEndpoint != "" {
 		options.Config.EndpointResolver = endpoints.ResolverFunc(func(service, region string, optFns ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
-			if service == endpoints.DynamodbServiceID {
+			if service == endpoints.DynamodbServiceID && endpoint != "" {
 				return endpoints.ResolvedEndpoint{
 					URL: endpoint,
 				}, nil
 			}
 
+			if service == sts.EndpointsID && stsEndpoint != "" {
+				return endpoints.ResolvedEndpoint{
+					URL: stsEndpoint,
+				}, nil
+			}
+
 			return endpoints.DefaultResolver().EndpointFor(service, region, optFns...)
 		})
 	}
@@ -143,16 +238,28 @@ func newClient(region string, accessKey string, secretKey string, token string,
 		}
 	}
 
-	return dynamodb.New(sess), nil
+	return sess, nil
+}
+
+// getCallerIdentity checks that the session credentials are valid and returns the account ID
+// and ARN of the caller.
+func getCallerIdentity(ctx context.Context, sess *session.Session) (string, string, error) {
+	out, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", "", fmt.Errorf("error validating AWS credentials: %w", err)
+	}
+
+	return aws.StringValue(out.Account), aws.StringValue(out.Arn), nil
 }
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	accessKey := d.Get("access_key").(string)
 	secretKey := d.Get("secret_key").(string)
 	token := d.Get("token").(string)
 	profile := d.Get("profile").(string)
 	region := d.Get("region").(string)
 	endpoint := d.Get("dynamodb_endpoint").(string)
+	stsEndpoint := d.Get("sts_endpoint").(string)
 	assume_role_config := d.Get("assume_role").([]interface{})
 	validate := d.Get("validate").(bool)
 
@@ -164,13 +271,98 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		}
 	}
 
-	c, err := newClient(region, accessKey, secretKey, token, profile, endpoint, role_arn, validate)
+	sess, err := newSession(region, accessKey, secretKey, token, profile, endpoint, stsEndpoint, role_arn, validate)
 	if err != nil {
-		return nil, err
+		return nil, diag.FromErr(err)
 	}
 
-	return &GSIProvider{
-		c:          c,
-		autoImport: d.Get("auto_import").(bool),
-	}, nil
+	// The durations are validated by the schema.
+	pollMin, _ := time.ParseDuration(d.Get("poll_min_interval").(string))
+	pollMax, _ := time.ParseDuration(d.Get("poll_max_interval").(string))
+	if pollMin > pollMax {
+		return nil, diag.Errorf("poll_min_interval (%s) must not be greater than poll_max_interval (%s)", pollMin, pollMax)
+	}
+
+	tables := newTableCache()
+	pollers := newTablePollers(pollMin, pollMax)
+	batcher := newUpdateBatcher()
+	p := &GSIProvider{
+		c:                   newDynamoDBClient(dynamodb.New(sess), region, tables, pollers, batcher),
+		autoImport:          d.Get("auto_import").(bool),
+		deletionProtection:  d.Get("deletion_protection_enabled").(bool),
+		readOnly:            d.Get("read_only").(bool),
+		sess:                sess,
+		region:              region,
+		tables:              tables,
+		pollers:             pollers,
+		batcher:             batcher,
+		detectAutoscaling:   d.Get("detect_autoscaling").(bool),
+		autoscalingEndpoint: d.Get("application_autoscaling_endpoint").(string),
+		dynamodbEndpoint:    endpoint,
+	}
+	p.c.autoscaling = p.autoscalingClient(region)
+
+	// DynamoDB Local has no STS, the credentials are only checked against a DynamoDB endpoint along
+	// with an STS endpoint.
+	if validate && (endpoint == "" || stsEndpoint != "") {
+		if p.accountID, p.callerARN, err = getCallerIdentity(ctx, sess); err != nil {
+			return nil, diag.FromErr(err)
+		}
+	}
+
+	return p, nil
+}
+
+// client returns the DynamoDB client for the given region, the provider region is used if empty.
+func (p *GSIProvider) client(region string) *dynamoDBClient {
+	if region == "" || region == p.region {
+		return p.c
+	}
+
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	if c, ok := p.clients[region]; ok {
+		return c
+	}
+
+	if p.clients == nil {
+		p.clients = map[string]*dynamoDBClient{}
+	}
+	c := newDynamoDBClient(dynamodb.New(p.sess, aws.NewConfig().WithRegion(region)), region, p.tables, p.pollers, p.batcher)
+	c.autoscaling = p.autoscalingClient(region)
+	p.clients[region] = c
+
+	return c
+}
+
+// autoscalingClient returns the Application Auto Scaling client for the given region. There is none if
+// only DynamoDB has a custom endpoint, such as DynamoDB Local, since the indexes it serves cannot be
+// autoscaled on AWS.
+func (p *GSIProvider) autoscalingClient(region string) applicationautoscalingiface.ApplicationAutoScalingAPI {
+	if p.dynamodbEndpoint != "" && p.autoscalingEndpoint == "" {
+		return nil
+	}
+
+	config := aws.NewConfig().WithRegion(region)
+	if p.autoscalingEndpoint != "" {
+		config = config.WithEndpoint(p.autoscalingEndpoint)
+	}
+	return applicationautoscaling.New(p.sess, config)
+}
+
+// resolvedRegion returns the region used for the given resource region.
+func (p *GSIProvider) resolvedRegion(region string) string {
+	if region == "" {
+		return p.region
+	}
+	return region
+}
+
+// checkWritable returns an error if the provider is not allowed to modify tables.
+func (p *GSIProvider) checkWritable(action string, tn string, in string) error {
+	if p.readOnly {
+		return fmt.Errorf("cannot %s GSI %s on table %s: the provider is configured with read_only = true", action, in, tn)
+	}
+	return nil
 }
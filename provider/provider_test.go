@@ -1,7 +1,15 @@
 package provider
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func TestProvider(t *testing.T) {
@@ -9,3 +17,110 @@ func TestProvider(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestAutoscalingClient(t *testing.T) {
+	p := &GSIProvider{sess: session.Must(session.NewSession()), dynamodbEndpoint: "http://localhost:8000/"}
+	if c := p.autoscalingClient("us-east-1"); c != nil {
+		t.Errorf("expected no Application Auto Scaling client with only a DynamoDB endpoint, got %v", c)
+	}
+
+	p.autoscalingEndpoint = "http://localhost:8001/"
+	if c := p.autoscalingClient("us-east-1"); c == nil {
+		t.Error("expected an Application Auto Scaling client with its own endpoint")
+	}
+}
+
+const testCallerIdentityResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::123456789012:user/test</Arn>
+    <UserId>AIDATEST</UserId>
+    <Account>123456789012</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata>
+    <RequestId>test-request</RequestId>
+  </ResponseMetadata>
+</GetCallerIdentityResponse>`
+
+// newFakeSTS serves GetCallerIdentity, or an access denied error if denied is set, and counts the calls.
+func newFakeSTS(t *testing.T, denied bool) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "GetCallerIdentity" {
+			http.Error(w, "unsupported operation", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		if denied {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error><RequestId>test-request</RequestId></ErrorResponse>`))
+			return
+		}
+		w.Write([]byte(testCallerIdentityResponse))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testProviderData(t *testing.T, raw map[string]interface{}) *schema.ResourceData {
+	config := map[string]interface{}{
+		"access_key": "local_id",
+		"secret_key": "local_secret",
+		"region":     "us-east-1",
+	}
+	for k, v := range raw {
+		config[k] = v
+	}
+	return schema.TestResourceDataRaw(t, Provider().Schema, config)
+}
+
+func TestProviderConfigureCallerIdentity(t *testing.T) {
+	srv, calls := newFakeSTS(t, false)
+
+	m, diags := providerConfigure(context.Background(), testProviderData(t, map[string]interface{}{"sts_endpoint": srv.URL}))
+	if diags.HasError() {
+		t.Fatalf("unexpected error %v", diags)
+	}
+	p := m.(*GSIProvider)
+	if p.accountID != "123456789012" || p.callerARN != "arn:aws:iam::123456789012:user/test" {
+		t.Errorf("expected the caller identity to be stored, got %q and %q", p.accountID, p.callerARN)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected a single GetCallerIdentity call, got %d", n)
+	}
+
+	// The credentials are also checked against a DynamoDB endpoint if an STS endpoint is set.
+	m, diags = providerConfigure(context.Background(), testProviderData(t, map[string]interface{}{"sts_endpoint": srv.URL, "dynamodb_endpoint": "http://localhost:8000/"}))
+	if diags.HasError() || m.(*GSIProvider).accountID != "123456789012" {
+		t.Errorf("expected the credentials to be validated, got %v", diags)
+	}
+
+	m, diags = providerConfigure(context.Background(), testProviderData(t, map[string]interface{}{"sts_endpoint": srv.URL, "validate": false}))
+	if diags.HasError() || m.(*GSIProvider).accountID != "" {
+		t.Errorf("expected no validation, got %v", diags)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected no GetCallerIdentity call without validate, got %d calls", n)
+	}
+}
+
+func TestProviderConfigureCallerIdentityDenied(t *testing.T) {
+	srv, _ := newFakeSTS(t, true)
+
+	_, diags := providerConfigure(context.Background(), testProviderData(t, map[string]interface{}{"sts_endpoint": srv.URL}))
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "error validating AWS credentials") {
+		t.Errorf("expected the validation to fail, got %v", diags)
+	}
+}
+
+func TestProviderConfigureDynamoDBLocal(t *testing.T) {
+	// DynamoDB Local with static keys has no STS to validate them against.
+	m, diags := providerConfigure(context.Background(), testProviderData(t, map[string]interface{}{"dynamodb_endpoint": "http://localhost:8000/"}))
+	if diags.HasError() {
+		t.Fatalf("expected the validation to be skipped, got %v", diags)
+	}
+	if p := m.(*GSIProvider); p.accountID != "" {
+		t.Errorf("expected no caller identity, got %q", p.accountID)
+	}
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const defaultScanSegments = 4
+
+// parallelScan scans a table or index in parallel segments, calling page with the output of each page
+// until it returns false or the segment is scanned. The input is copied for each segment and passed
+// to page, which may change it for the next page.
+func parallelScan(ctx context.Context, c *dynamoDBClient, input *dynamodb.ScanInput, segments int, page func(segment int, input *dynamodb.ScanInput, out *dynamodb.ScanOutput) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The first error cancels the other segments, whose requests then fail with RequestCanceled.
+	var first error
+	var once sync.Once
+	var wg sync.WaitGroup
+	for segment := 0; segment < segments; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+
+			in := *input
+			in.Segment = aws.Int64(int64(segment))
+			in.TotalSegments = aws.Int64(int64(segments))
+			for {
+				out, err := c.ScanWithContext(ctx, &in)
+				if err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+					return
+				}
+
+				if !page(segment, &in, out) || len(out.LastEvaluatedKey) == 0 {
+					return
+				}
+				in.ExclusiveStartKey = out.LastEvaluatedKey
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	if first != nil {
+		return fmt.Errorf("error scanning Dynamodb Table (%s): %w", aws.StringValue(input.TableName), first)
+	}
+	return nil
+}
+
+// keyAttributeNames returns placeholders for the key attributes of an index, sorted by name, and their
+// expression attribute names.
+func keyAttributeNames(keys map[string]string) ([]string, map[string]*string) {
+	attributes := make([]string, 0, len(keys))
+	for name := range keys {
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+
+	placeholders := make([]string, 0, len(attributes))
+	names := map[string]*string{}
+	for idx, name := range attributes {
+		placeholder := fmt.Sprintf("#k%d", idx)
+		placeholders = append(placeholders, placeholder)
+		names[placeholder] = aws.String(name)
+	}
+	return placeholders, names
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestParallelScanError(t *testing.T) {
+	const segments = 4
+
+	// The last segment fails, the others wait until their request is canceled.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		input := &dynamodb.ScanInput{}
+		if err := jsonutil.UnmarshalJSON(input, r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if aws.Int64Value(input.Segment) != segments-1 {
+			<-r.Context().Done()
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ValidationException","message":"segment failed"}`))
+	}))
+	defer srv.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("local_id", "local_secret", "")).
+		WithMaxRetries(0)))
+	c := newDynamoDBClient(dynamodb.New(sess), "us-east-1", nil, nil, nil)
+
+	err := parallelScan(context.Background(), c, &dynamodb.ScanInput{TableName: aws.String("test_table")}, segments, func(int, *dynamodb.ScanInput, *dynamodb.ScanOutput) bool {
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "ValidationException") {
+		t.Errorf("expected the error of the failed segment, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Table descriptions are only cached long enough to be shared by the indexes of a table refreshed
+// together, the cache is not meant to save calls across operations.
+const tableCacheTTL = 5 * time.Second
+
+// dynamoDBClient is a DynamoDB client for a region which shares the table descriptions, polling
+// loops and index updates between the resources of the provider.
+type dynamoDBClient struct {
+	*dynamodb.DynamoDB
+	region  string
+	tables  *tableCache
+	pollers *tablePollers
+	batcher *updateBatcher
+
+	// autoscaling is nil if the client cannot reach Application Auto Scaling.
+	autoscaling applicationautoscalingiface.ApplicationAutoScalingAPI
+}
+
+func newDynamoDBClient(c *dynamodb.DynamoDB, region string, tables *tableCache, pollers *tablePollers, batcher *updateBatcher) *dynamoDBClient {
+	return &dynamoDBClient{
+		DynamoDB: c,
+		region:   region,
+		tables:   tables,
+		pollers:  pollers,
+		batcher:  batcher,
+	}
+}
+
+// describeTable returns the description of a table, concurrent calls for the same table share a
+// single DescribeTable call. The description must not be modified.
+func (c *dynamoDBClient) describeTable(ctx context.Context, tn string) (*dynamodb.TableDescription, error) {
+	describe := func() (*dynamodb.TableDescription, error) {
+		t, err := c.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(tn),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return t.Table, nil
+	}
+
+	if c.tables == nil {
+		return describe()
+	}
+	return c.tables.get(ctx, c.region+":"+tn, describe)
+}
+
+// updateTable updates a table and invalidates its cached description.
+func (c *dynamoDBClient) updateTable(ctx context.Context, input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	out, err := c.UpdateTableWithContext(ctx, input)
+	if c.tables != nil {
+		c.tables.invalidate(c.region + ":" + aws.StringValue(input.TableName))
+	}
+	return out, err
+}
+
+type tableCacheEntry struct {
+	// done is closed once the description is fetched.
+	done    chan struct{}
+	table   *dynamodb.TableDescription
+	err     error
+	expires time.Time
+}
+
+func (e *tableCacheEntry) expired() bool {
+	select {
+	case <-e.done:
+		return e.err != nil || time.Now().After(e.expires)
+	default:
+		return false
+	}
+}
+
+// tableCache is a short-lived cache of table descriptions which deduplicates concurrent calls.
+type tableCache struct {
+	mu      sync.Mutex
+	entries map[string]*tableCacheEntry
+}
+
+func newTableCache() *tableCache {
+	return &tableCache{
+		entries: map[string]*tableCacheEntry{},
+	}
+}
+
+func (tc *tableCache) get(ctx context.Context, key string, describe func() (*dynamodb.TableDescription, error)) (*dynamodb.TableDescription, error) {
+	tc.mu.Lock()
+	e, ok := tc.entries[key]
+	if !ok || e.expired() {
+		e = &tableCacheEntry{done: make(chan struct{})}
+		tc.entries[key] = e
+		tc.mu.Unlock()
+
+		e.table, e.err = describe()
+		e.expires = time.Now().Add(tableCacheTTL)
+		close(e.done)
+
+		return e.table, e.err
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return e.table, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (tc *tableCache) invalidate(key string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	delete(tc.entries, key)
+}
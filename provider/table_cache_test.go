@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestTableCache(t *testing.T) {
+	tc := newTableCache()
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	describe := func() (*dynamodb.TableDescription, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &dynamodb.TableDescription{TableName: aws.String("test_table")}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if table, err := tc.get(ctx, "test_table", describe); err != nil || aws.StringValue(table.TableName) != "test_table" {
+				t.Errorf("unexpected result (%v, %v)", table, err)
+			}
+		}()
+	}
+
+	// Let the goroutines join the pending call before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected concurrent calls to be deduplicated, got %d calls", calls)
+	}
+
+	if _, err := tc.get(ctx, "test_table", describe); err != nil || calls != 1 {
+		t.Errorf("expected the description to be cached, got %d calls", calls)
+	}
+
+	tc.invalidate("test_table")
+	if _, err := tc.get(ctx, "test_table", describe); err != nil || calls != 2 {
+		t.Errorf("expected a new call after invalidation, got %d calls", calls)
+	}
+
+	failure := func() (*dynamodb.TableDescription, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("throttled")
+	}
+	if _, err := tc.get(ctx, "other_table", failure); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := tc.get(ctx, "other_table", describe); err != nil || calls != 4 {
+		t.Errorf("expected errors not to be cached, got %d calls", calls)
+	}
+}
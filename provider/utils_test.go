@@ -1,8 +1,19 @@
 package provider
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -58,8 +69,54 @@ func createTableWithMode(c *dynamodb.DynamoDB, tn string, attributes map[string]
 	return nil
 }
 
-func testProviderConfigure(autoImport bool) schema.ConfigureFunc {
-	return func(d *schema.ResourceData) (interface{}, error) {
+func createGSI(c *dynamodb.DynamoDB, tn, in string, hashKey, hashKeyType, rangeKey, rangeKeyType string) error {
+	_, err := c.UpdateTable(&dynamodb.UpdateTableInput{
+		TableName: aws.String(tn),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(hashKey),
+				AttributeType: aws.String(hashKeyType),
+			},
+			{
+				AttributeName: aws.String(rangeKey),
+				AttributeType: aws.String(rangeKeyType),
+			},
+		},
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{
+				Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName: aws.String(in),
+					KeySchema: []*dynamodb.KeySchemaElement{
+						{
+							AttributeName: aws.String(hashKey),
+							KeyType:       aws.String(dynamodb.KeyTypeHash),
+						},
+						{
+							AttributeName: aws.String(rangeKey),
+							KeyType:       aws.String(dynamodb.KeyTypeRange),
+						},
+					},
+					Projection: &dynamodb.Projection{
+						ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly),
+					},
+					ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
+						ReadCapacityUnits:  aws.Int64(10),
+						WriteCapacityUnits: aws.Int64(10),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = waitDynamoDBGSIActive(context.Background(), newDynamoDBClient(c, "", nil, nil, nil), tn, in, createGSITimeout)
+	return err
+}
+
+func testProviderConfigure(autoImport bool) schema.ConfigureContextFunc {
+	return func(_ context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
 		accessKey := d.Get("access_key").(string)
 		secretKey := d.Get("secret_key").(string)
 		token := d.Get("token").(string)
@@ -67,14 +124,212 @@ func testProviderConfigure(autoImport bool) schema.ConfigureFunc {
 		region := d.Get("region").(string)
 		endpoint := d.Get("dynamodb_endpoint").(string)
 
-		c, err := newClient(region, accessKey, secretKey, token, profile, endpoint, "", true)
+		sess, err := newSession(region, accessKey, secretKey, token, profile, endpoint, "", "", true)
 		if err != nil {
-			return nil, err
+			return nil, diag.FromErr(err)
 		}
 
+		tables := newTableCache()
+		pollers := newTablePollers(defaultPollMinInterval, defaultPollMaxInterval)
+		batcher := newUpdateBatcher()
+		// DynamoDB Local has no Application Auto Scaling, the detection of autoscaling stays disabled.
 		return &GSIProvider{
-			c:          c,
-			autoImport: autoImport,
+			c:                  newDynamoDBClient(dynamodb.New(sess), region, tables, pollers, batcher),
+			autoImport:         autoImport,
+			deletionProtection: d.Get("deletion_protection_enabled").(bool),
+			readOnly:           d.Get("read_only").(bool),
+			sess:               sess,
+			region:             region,
+			tables:             tables,
+			pollers:            pollers,
+			batcher:            batcher,
 		}, nil
 	}
 }
+
+// testTableResource manages a PROVISIONED table with a string hash key and a capacity of 10, so that a
+// table can be created in the same apply as its indexes.
+func testTableResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"hash_key": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+		},
+		CreateContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, err := newTestClient()
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			tn := d.Get("name").(string)
+			hashKey := d.Get("hash_key").(string)
+			if err := createTable(c, tn, map[string]string{hashKey: "S"}, map[string]string{hashKey: "HASH"}); err != nil {
+				return diag.FromErr(err)
+			}
+			d.SetId(tn)
+			return nil
+		},
+		ReadContext: schema.NoopContext,
+		DeleteContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, err := newTestClient()
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			_, err = c.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(d.Id())})
+			return diag.FromErr(err)
+		},
+	}
+}
+
+// testProviderWithTable returns the test provider with a gsi_test_table resource.
+func testProviderWithTable() *schema.Provider {
+	p := providerWithConfigure(testProviderConfigure(false))
+	p.ResourcesMap["gsi_test_table"] = testTableResource()
+	return p
+}
+
+// fakeDynamoDB serves DescribeTable and UpdateTable for a single table, the indexes go through a list of
+// statuses, one per description, and stay in the last one.
+type fakeDynamoDB struct {
+	mu       sync.Mutex
+	table    *dynamodb.TableDescription
+	statuses map[string][]string
+	updates  []*dynamodb.UpdateTableInput
+
+	// createStatuses are the statuses of the indexes created by UpdateTable.
+	createStatuses []string
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out interface{}
+	switch r.Header.Get("X-Amz-Target") {
+	case "DynamoDB_20120810.DescribeTable":
+		for _, i := range f.table.GlobalSecondaryIndexes {
+			in := aws.StringValue(i.IndexName)
+			i.IndexStatus = aws.String(f.statuses[in][0])
+			if len(f.statuses[in]) > 1 {
+				f.statuses[in] = f.statuses[in][1:]
+			}
+		}
+		out = &dynamodb.DescribeTableOutput{Table: f.table}
+	case "DynamoDB_20120810.UpdateTable":
+		input := &dynamodb.UpdateTableInput{}
+		if err := jsonutil.UnmarshalJSON(input, r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.updates = append(f.updates, input)
+		f.table.AttributeDefinitions = input.AttributeDefinitions
+		for _, u := range input.GlobalSecondaryIndexUpdates {
+			if u.Create != nil {
+				f.table.GlobalSecondaryIndexes = append(f.table.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+					IndexName:  u.Create.IndexName,
+					KeySchema:  u.Create.KeySchema,
+					Projection: u.Create.Projection,
+				})
+				f.statuses[aws.StringValue(u.Create.IndexName)] = f.createStatuses
+			}
+			if u.Delete != nil {
+				for idx, i := range f.table.GlobalSecondaryIndexes {
+					if aws.StringValue(i.IndexName) == aws.StringValue(u.Delete.IndexName) {
+						f.table.GlobalSecondaryIndexes = append(f.table.GlobalSecondaryIndexes[:idx], f.table.GlobalSecondaryIndexes[idx+1:]...)
+						break
+					}
+				}
+			}
+		}
+		out = &dynamodb.UpdateTableOutput{TableDescription: f.table}
+	default:
+		http.Error(w, "unsupported operation", http.StatusBadRequest)
+		return
+	}
+
+	body, err := jsonutil.BuildJSON(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Write(body)
+}
+
+// newFakeDynamoDBProvider returns a provider whose DynamoDB client is served by f.
+func newFakeDynamoDBProvider(t *testing.T, f *fakeDynamoDB) *GSIProvider {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("local_id", "local_secret", "")).
+		WithMaxRetries(0)))
+	pollers := newTablePollers(time.Millisecond, 10*time.Millisecond)
+	return &GSIProvider{
+		c:       newDynamoDBClient(dynamodb.New(sess), "us-east-1", nil, pollers, nil),
+		sess:    sess,
+		region:  "us-east-1",
+		pollers: pollers,
+	}
+}
+
+// testCreateResourceData returns the data of an index being created with a create timeout, raw is set
+// over the defaults of a PROVISIONED index on test_table.
+func testCreateResourceData(t *testing.T, timeout time.Duration, raw map[string]interface{}) *schema.ResourceData {
+	r := dynamoDBGSIResource()
+	r.Timeouts.Create = schema.DefaultTimeout(timeout)
+	d := r.Data(nil)
+
+	values := map[string]interface{}{
+		"name":              "basic_index",
+		"table_name":        "test_table",
+		"hash_key":          "p",
+		"hash_key_type":     "S",
+		"projection_type":   "KEYS_ONLY",
+		"billing_mode":      dynamodb.BillingModeProvisioned,
+		"read_capacity":     5,
+		"write_capacity":    5,
+		"on_create_failure": onCreateFailureKeep,
+	}
+	for k, v := range raw {
+		values[k] = v
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.MarkNewResource()
+
+	return d
+}
+
+// testTableDescription describes test_table with a string hash key p and an index basic_index on it.
+func testTableDescription() *dynamodb.TableDescription {
+	return &dynamodb.TableDescription{
+		TableName: aws.String("test_table"),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("p"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+			{
+				IndexName:  aws.String("basic_index"),
+				KeySchema:  []*dynamodb.KeySchemaElement{{AttributeName: aws.String("p"), KeyType: aws.String(dynamodb.KeyTypeHash)}},
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly)},
+				ProvisionedThroughput: &dynamodb.ProvisionedThroughputDescription{
+					ReadCapacityUnits:  aws.Int64(5),
+					WriteCapacityUnits: aws.Int64(5),
+				},
+			},
+		},
+	}
+}
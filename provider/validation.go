@@ -3,6 +3,7 @@ package provider
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -28,3 +29,20 @@ func stringInSlice(valid []string, ignoreCase bool) schema.SchemaValidateFunc {
 		return warnings, errors
 	}
 }
+
+// validateDuration tests if the provided value is a string which can be parsed as a positive duration.
+func validateDuration(i interface{}, k string) (warnings []string, errors []error) {
+	v, ok := i.(string)
+	if !ok {
+		errors = append(errors, fmt.Errorf("expected type of %s to be string", k))
+		return warnings, errors
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("expected %s to be a duration, got %s: %s", k, v, err))
+	} else if d <= 0 {
+		errors = append(errors, fmt.Errorf("expected %s to be a positive duration, got %s", k, v))
+	}
+	return warnings, errors
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// countItems counts the items of a table, or of one of its indexes if in is not empty, in parallel
+// segments. Only the items matching filter are counted if it is not empty.
+func countItems(ctx context.Context, c *dynamoDBClient, tn string, in string, filter string, names map[string]*string, segments int) (int64, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tn),
+		Select:    aws.String(dynamodb.SelectCount),
+	}
+	if in != "" {
+		input.IndexName = aws.String(in)
+	}
+	if filter != "" {
+		input.FilterExpression = aws.String(filter)
+		input.ExpressionAttributeNames = names
+	}
+
+	var count int64
+	err := parallelScan(ctx, c, input, segments, func(_ int, _ *dynamodb.ScanInput, out *dynamodb.ScanOutput) bool {
+		atomic.AddInt64(&count, aws.Int64Value(out.Count))
+		return true
+	})
+	return count, err
+}
+
+// withinTolerance tells whether the number of items of an index differs from the number of table
+// items with every key attribute by at most tolerance, relative to the latter.
+func withinTolerance(tableCount int64, indexCount int64, tolerance float64) bool {
+	return math.Abs(float64(tableCount-indexCount)) <= tolerance*float64(tableCount)
+}
+
+// verifyCreate compares the number of items of a created index with the number of table items which
+// have every key attribute once it is backfilled. It returns a warning if they differ beyond the
+// tolerance, or an error if fail_on_mismatch is set.
+func verifyCreate(ctx context.Context, d *schema.ResourceData, c *dynamoDBClient, tn string, in string, keys map[string]string) (diag.Diagnostics, error) {
+	v, ok := d.GetOk("verify_after_create")
+	if !ok {
+		return nil, nil
+	}
+
+	// An empty block has no attributes set.
+	config, _ := v.([]interface{})[0].(map[string]interface{})
+	segments := defaultScanSegments
+	tolerance := 0.0
+	failOnMismatch := false
+	if config != nil {
+		segments = config["segments"].(int)
+		tolerance = config["tolerance"].(float64)
+		failOnMismatch = config["fail_on_mismatch"].(bool)
+	}
+
+	if _, err := waitDynamoDBGSIBackfilled(ctx, c, tn, in, d.Timeout(schema.TimeoutCreate)); err != nil {
+		return nil, fmt.Errorf("error waiting for DynamoDB GSI (%s) backfill on table %s: %w", in, tn, err)
+	}
+
+	placeholders, names := keyAttributeNames(keys)
+	conditions := make([]string, 0, len(placeholders))
+	for _, placeholder := range placeholders {
+		conditions = append(conditions, fmt.Sprintf("attribute_exists(%s)", placeholder))
+	}
+	tableCount, err := countItems(ctx, c, tn, "", strings.Join(conditions, " AND "), names, segments)
+	if err != nil {
+		return nil, err
+	}
+	indexCount, err := countItems(ctx, c, tn, in, "", nil, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	if withinTolerance(tableCount, indexCount, tolerance) {
+		return nil, nil
+	}
+
+	mismatch := fmt.Errorf("DynamoDB GSI (%s) holds %d items but table %s has %d items with every key attribute, beyond a tolerance of %g", in, indexCount, tn, tableCount, tolerance)
+	if failOnMismatch {
+		return nil, mismatch
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("verification of DynamoDB GSI (%s) on table %s found a different number of items", in, tn),
+		Detail:   fmt.Sprintf("%s.\n\nWrites to the table during the verification also make the counts differ.", mismatch),
+	}}, nil
+}
@@ -0,0 +1,25 @@
+package provider
+
+import "testing"
+
+func TestWithinTolerance(t *testing.T) {
+	cases := []struct {
+		tableCount, indexCount int64
+		tolerance              float64
+		expected               bool
+	}{
+		{0, 0, 0, true},
+		{10, 10, 0, true},
+		{10, 9, 0, false},
+		{10, 9, 0.1, true},
+		{10, 11, 0.1, true},
+		{10, 12, 0.1, false},
+		{0, 1, 1, false},
+	}
+
+	for _, tc := range cases {
+		if actual := withinTolerance(tc.tableCount, tc.indexCount, tc.tolerance); actual != tc.expected {
+			t.Errorf("withinTolerance(%d, %d, %g) = %t, expected %t", tc.tableCount, tc.indexCount, tc.tolerance, actual, tc.expected)
+		}
+	}
+}